@@ -0,0 +1,19 @@
+package analysis
+
+// Receives debug output of the compiler stages, implemented by *log.Logger
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Options shared by lexer, parser, context and compiler
+type Options struct {
+	Logger Logger // Debug output is discarded if nil
+}
+
+func (o Options) Debugf(format string, v ...any) {
+	if o.Logger == nil {
+		return
+	}
+
+	o.Logger.Printf(format, v...)
+}
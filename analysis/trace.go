@@ -1,7 +1,37 @@
 package analysis
 
+import "fmt"
+
+// Span of source text, indices count runes. Rows and columns start at 1,
+// the end is the location after the last rune of the span.
 type SourceTrace struct {
-	Index  int
-	Row    int
-	Column int
+	File      string // Path of the source file
+	Index     int
+	End       int
+	Row       int
+	Column    int
+	EndRow    int
+	EndColumn int
+}
+
+// Span from the start of t to the end of other
+func (t SourceTrace) To(other SourceTrace) SourceTrace {
+	if other.End < t.Index || other.File != t.File {
+		return t
+	}
+
+	t.End = other.End
+	t.EndRow = other.EndRow
+	t.EndColumn = other.EndColumn
+
+	return t
+}
+
+// Location of the start: file:row:column
+func (t SourceTrace) String() string {
+	if t.File == "" {
+		return fmt.Sprintf("%d:%d", t.Row, t.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", t.File, t.Row, t.Column)
 }
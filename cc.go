@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// C compilers searched in PATH when none is configured
+var cCompilers = []string{"cc", "gcc", "clang"}
+
+type cCompileError struct {
+	message string
+}
+
+func (e cCompileError) Error() string {
+	return e.message
+}
+
+// Exit status of a program started by comet run
+type exitStatusError struct {
+	code   int
+	signal syscall.Signal // Signal that killed the program, 0 if it exited
+}
+
+func (e exitStatusError) Error() string {
+	if e.signal != 0 {
+		return fmt.Sprintf("Program killed by signal %d (%s)", int(e.signal), e.signal)
+	}
+
+	return fmt.Sprintf("Program exited with status %d", e.code)
+}
+
+// Finds the C compiler: -cc flag, then $CC, then PATH
+func findCCompiler(configured string) (string, error) {
+	if configured == "" {
+		configured = os.Getenv("CC")
+	}
+
+	if configured != "" {
+		path, err := exec.LookPath(configured)
+
+		if err != nil {
+			return "", fmt.Errorf("C compiler %s not found: %w", configured, err)
+		}
+
+		return path, nil
+	}
+
+	for _, name := range cCompilers {
+		path, err := exec.LookPath(name)
+
+		if err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("No C compiler found, tried %s (set -cc or $CC)", strings.Join(cCompilers, ", "))
+}
+
+// Compiles C source into an executable at output.
+// Diagnostics of the C compiler point to the source through #line directives.
+func compileNative(cc string, c string, output string) error {
+	dir, err := os.MkdirTemp("", "comet-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	cPath := filepath.Join(dir, "main.c")
+
+	err = os.WriteFile(cPath, []byte(c), 0644)
+
+	if err != nil {
+		return err
+	}
+
+	stderr := bytes.Buffer{}
+
+	cmd := exec.Command(cc, cPath, "-o", output)
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	// The generated C contains #line directives with source files and lines,
+	// only the runtime helpers before the first directive still name the C
+	// file, which is removed once compiled
+	diagnostics := strings.ReplaceAll(stderr.String(), cPath, "<generated>")
+
+	if err != nil {
+		return cCompileError{message: strings.TrimRight(diagnostics, "\n")}
+	}
+
+	os.Stderr.WriteString(diagnostics)
+
+	return nil
+}
+
+// Runs executable forwarding standard streams and the exit status
+func runNative(path string, args []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// ExitCode is -1 for killed programs, shells report those as 128 + signal
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		if ok && status.Signaled() {
+			return exitStatusError{code: 128 + int(status.Signal()), signal: status.Signal()}
+		}
+
+		return exitStatusError{code: exitErr.ExitCode()}
+	}
+
+	return err
+}
@@ -2,36 +2,51 @@ package compiler
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/yonedash/comet/analysis"
+	"github.com/yonedash/comet/diagnostics"
 	"github.com/yonedash/comet/parser"
 )
 
 type CompileError struct {
-	message string
-	trace   analysis.SourceTrace
+	diagnostic diagnostics.Diagnostic
 }
 
 func (e CompileError) Error() string {
-	return e.message
+	return e.diagnostic.Error()
 }
 
-func compileError(statement parser.Statement, message string) error {
-	// Return error if unknown character is in source
-	trace := statement.Trace
-
-	row, col := trace.Row, trace.Column
-	msg := fmt.Sprintf("%s @ %d:%d >> %+v", message, row, col, statement)
+func (e CompileError) Unwrap() error {
+	return e.diagnostic
+}
 
-	return CompileError{message: msg, trace: trace}
+func compileError(statement parser.Statement, message string) error {
+	return CompileError{diagnostic: diagnostics.New(diagnostics.Error, diagnostics.CodeCompile, statement.Trace, message)}
 }
 
 type compiler struct {
-	head            string
-	prepend         string
-	indent          int
-	booleanImported bool
-	imports         []string
+	options           analysis.Options
+	temporaries       int    // Count of generated temporary variables
+	line              int    // Last source line referenced by a #line directive
+	file              string // ^ source file
+	head              string
+	prepend           string
+	indent            int
+	booleanImported   bool
+	helpers           []string // Names of generated helper functions
+	temporariesUsed   bool     // Current statement registered temporaries
+	temporariesMarked bool     // Current function frees temporaries
+	imports           []string
+	breakTargets      []*breakTarget
+}
+
+// Loop or switch a break statement would leave in C
+type breakTarget struct {
+	loop  bool
+	label string // Label behind the loop, set once a break needs to jump out of a switch
 }
 
 func (c *compiler) cImportLib(path string) {
@@ -43,11 +58,12 @@ func (c *compiler) cImportLib(path string) {
 	c.imports = append(c.imports, path)
 }
 
-func CompileC(root *parser.Statement) (string, error) {
-	cl := &compiler{indent: -1}
+func CompileC(root *parser.Statement, options analysis.Options) (string, error) {
+	cl := &compiler{options: options, indent: -1}
 	content, err := compile(cl, root, nil)
 
 	cl.cImportLib("sys/types.h")
+	cl.cImportLib("stdint.h")
 
 	if err != nil {
 		return "", err
@@ -70,12 +86,36 @@ func compile(cl *compiler, statement *parser.Statement, context *parser.Scope) (
 		return compileScope(cl, statement)
 	case parser.FunctionDeclaration:
 		return compileFunction(cl, statement)
+	case parser.StructDeclaration:
+		return compileStructDeclaration(cl, statement)
+	case parser.EnumDeclaration:
+		return compileEnumDeclaration(cl, statement)
+	case parser.MatchStatement:
+		return compileMatch(cl, statement, context)
 	case parser.VariableDeclaration:
 		return compileVariableDeclaration(cl, statement)
 	case parser.VariableAssignment:
 		return compileVariableAssignment(cl, statement)
-	case parser.BinaryExpression, parser.IdentifierExpression, parser.NumberLiteral, parser.BooleanLiteral, parser.FunctionExpression:
+	case parser.BinaryExpression, parser.UnaryExpression, parser.ConversionExpression, parser.FieldExpression, parser.StructLiteral, parser.IdentifierExpression, parser.NumberLiteral, parser.BooleanLiteral:
 		return compileExpression(cl, statement, context)
+	case parser.FunctionExpression:
+		call, err := compileExpression(cl, statement, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		return indent(cl) + call + ";", nil
+	case parser.ConditionalStatement:
+		return compileConditional(cl, statement, context)
+	case parser.LoopStatement:
+		return compileLoop(cl, statement)
+	case parser.ReturnStatement:
+		return compileReturn(cl, statement)
+	case parser.BreakStatement:
+		return compileExit(cl, statement, compileBreak(cl))
+	case parser.ContinueStatement:
+		return compileExit(cl, statement, "continue;")
 	case parser.MemoryDeAllocation:
 		return compileMemoryDeAllocation(cl, statement)
 	case parser.ImportStatement:
@@ -105,13 +145,45 @@ func compileMemoryDeAllocation(cl *compiler, statement *parser.Statement) (strin
 
 	cl.cImportLib("stdlib.h")
 
-	return indent(cl) + "free(" + variable.VarName + ");", nil
+	// Slices and strings are the only allocated values
+	return indent(cl) + "free(" + variable.VarName + ".data);", nil
+}
+
+// Frees the variables left by a return, break or continue
+func compileCleanup(cl *compiler, statement *parser.Statement) (string, error) {
+	content := ""
+
+	for _, deAllocation := range statement.Cleanup {
+		freed, err := compileMemoryDeAllocation(cl, deAllocation)
+
+		if err != nil {
+			return "", err
+		}
+
+		if len(freed) > 0 {
+			content += freed + "\n"
+		}
+	}
+
+	return content, nil
+}
+
+// Compiles break or continue after freeing the variables it leaves
+func compileExit(cl *compiler, statement *parser.Statement, exit string) (string, error) {
+	cleanup, err := compileCleanup(cl, statement)
+
+	if err != nil {
+		return "", err
+	}
+
+	return cleanup + indent(cl) + exit, nil
 }
 
 var internalTypes = map[parser.TypeId]string{
 	// TODO: __UINT_FAST16_TYPE__ __INT16_TYPE__
 	parser.Void:          "void",
 	parser.Bool:          inferBoolean(),
+	parser.Rune:          "uint32_t",
 	parser.Int8:          "int8_t",
 	parser.Int16:         "int16_t",
 	parser.Int32:         "int32_t",
@@ -124,10 +196,17 @@ var internalTypes = map[parser.TypeId]string{
 	parser.Float64:       "double",
 	parser.Complex64:     "float _Complex",
 	parser.Complex128:    "double _Complex",
-	parser.String:        "char*",
 }
 
-func getTypeOfC(aType parser.ActualType) string {
+func getTypeOfC(cl *compiler, aType parser.ActualType) string {
+	if aType.Id == parser.Slice {
+		return importSlice(cl, *aType.ElementType)
+	}
+
+	if aType.Id == parser.String {
+		return importString(cl)
+	}
+
 	if aType.Id != parser.Custom {
 		return internalTypes[aType.Id]
 	}
@@ -135,6 +214,21 @@ func getTypeOfC(aType parser.ActualType) string {
 	return aType.CustomName
 }
 
+// Array sizes follow the declared name in C: int32_t a[4][5]
+func getArraySizesOfC(aType parser.ActualType) string {
+	sizes := ""
+
+	for _, size := range aType.ArraySizes {
+		sizes += fmt.Sprintf("[%d]", size)
+	}
+
+	return sizes
+}
+
+func isScalarBool(aType parser.ActualType) bool {
+	return aType.Id == parser.Bool && len(aType.ArraySizes) == 0
+}
+
 func compileVariableAssignment(cl *compiler, statement *parser.Statement) (string, error) {
 	content := ""
 	assignCount := len(statement.Expressions)
@@ -148,13 +242,37 @@ func compileVariableAssignment(cl *compiler, statement *parser.Statement) (strin
 		}
 
 		expr := statement.Expressions[i]
-		compiledExpr, err := compile(cl, expr, &statement.Context)
 
-		if err != nil {
-			return "", err
-		}
+		// Memory of the replaced slice or string is freed
+		if variable := statement.Context.GetVariable(identifier.Value); identifier.Type == parser.IdentifierExpression && isOwning(variable.VarType) {
+			compiledExpr := ""
+
+			if statement.Compound {
+				compiledExpr, err = compileConcatAssignment(cl, compiledIdentifier, expr, &statement.Context)
+			} else {
+				compiledExpr, err = compileOwned(cl, expr, variable.VarType, &statement.Context)
+			}
+
+			if err != nil {
+				return "", err
+			}
+
+			content += indent(cl) + fmt.Sprintf("%s_set(&%s, %s);", getTypeOfC(cl, variable.VarType), compiledIdentifier, compiledExpr)
+		} else {
+			compiledExpr, err := compileExpression(cl, expr, &statement.Context)
+
+			if err != nil {
+				return "", err
+			}
+
+			assign := " = "
 
-		content += indent(cl) + compiledIdentifier + " = " + compiledExpr + ";"
+			if statement.Compound {
+				assign = " " + binaryOperators[statement.Operator] + "= "
+			}
+
+			content += indent(cl) + compiledIdentifier + assign + compiledExpr + ";"
+		}
 
 		if i != assignCount-1 {
 			content += "\n"
@@ -164,7 +282,26 @@ func compileVariableAssignment(cl *compiler, statement *parser.Statement) (strin
 	return content, nil
 }
 
+// Compiles the fresh string of target += value
+func compileConcatAssignment(cl *compiler, target string, expr *parser.Statement, context *parser.Scope) (string, error) {
+	compiled, err := compileExpression(cl, expr, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s_concat(%s, %s)", importString(cl), target, compiled), nil
+}
+
 func compileVariableDeclaration(cl *compiler, statement *parser.Statement) (string, error) {
+	if statement.Destructure {
+		return compileDestructuringDeclaration(cl, statement)
+	}
+
+	if len(statement.Expressions) == 0 {
+		return compileZeroedDeclaration(cl, statement), nil
+	}
+
 	content := ""
 
 	assignCount := len(statement.Expressions)
@@ -184,6 +321,19 @@ func compileVariableDeclaration(cl *compiler, statement *parser.Statement) (stri
 
 		expr := statement.Expressions[i]
 		varType := statement.Types[i]
+		compile := compileExpression
+
+		// Arrays are initialized with a brace list
+		if expr.Type == parser.ArrayLiteral {
+			compile = compileArrayLiteral
+		}
+
+		if isOwning(varType) {
+			compile = func(cl *compiler, expr *parser.Statement, context *parser.Scope) (string, error) {
+				return compileOwned(cl, expr, varType, context)
+			}
+		}
+
 		compiledExpr, err := compile(cl, expr, &statement.Context)
 
 		if err != nil {
@@ -203,9 +353,9 @@ func compileVariableDeclaration(cl *compiler, statement *parser.Statement) (stri
 
 		statement.ContextVariable.ALLOCATED = true
 
-		content += indent(cl) + constant + getTypeOfC(varType) + " " + compiledIdentifier
+		content += indent(cl) + constant + getTypeOfC(cl, varType) + " " + compiledIdentifier + getArraySizesOfC(varType)
 
-		if varType.Id == parser.Bool {
+		if isScalarBool(varType) {
 			importBoolean(cl)
 
 			content += " = { value: " + compiledExpr + " }"
@@ -223,247 +373,1133 @@ func compileVariableDeclaration(cl *compiler, statement *parser.Statement) (stri
 	return content, nil
 }
 
-func compileExpression(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
-	if statement.Type == parser.NumberLiteral || statement.Type == parser.IdentifierExpression {
-		if statement.Type == parser.IdentifierExpression {
-			variable := context.GetVariable(statement.Value)
+// Variables declared without value start zeroed
+func compileZeroedDeclaration(cl *compiler, statement *parser.Statement) string {
+	lines := []string{}
 
-			if variable != nil && variable.VarType.Id == parser.Bool {
-				return statement.Value + ".value", nil
-			}
+	for i, identifier := range statement.Identifiers {
+		varType := statement.Types[i]
+		zero := "0"
+
+		if varType.Id == parser.Bool {
+			importBoolean(cl)
 		}
 
-		return statement.Value, nil
-	}
+		if varType.Id == parser.Bool || varType.Id == parser.Custom || isOwning(varType) || len(varType.ArraySizes) > 0 {
+			zero = "{ 0 }"
+		}
 
-	if statement.Type == parser.BinaryExpression {
-		return compileBinaryExpression(cl, statement, 0, context)
+		lines = append(lines, indent(cl)+getTypeOfC(cl, varType)+" "+identifier.Value+getArraySizesOfC(varType)+" = "+zero+";")
 	}
 
-	if statement.Type == parser.BooleanLiteral {
-		if statement.Value == "true" {
-			return "1", nil
-		}
-		return "0", nil
-	}
+	return strings.Join(lines, "\n")
+}
 
-	if statement.Type == parser.StringLiteral {
-		return "\"" + statement.Value + "\"", nil
+// Unpacks the return struct of the function expression into the declared variables
+func compileDestructuringDeclaration(cl *compiler, statement *parser.Statement) (string, error) {
+	expr := statement.Expressions[0]
+	compiledExpr, err := compileExpression(cl, expr, &statement.Context)
+
+	if err != nil {
+		return "", err
 	}
 
-	if statement.Type == parser.FunctionExpression {
-		args := ""
+	temporary := inferName(fmt.Sprintf("destructure%d", cl.temporaries))
+	cl.temporaries++
 
-		argCount := len(statement.Expressions)
-		for i := 0; i < argCount; i++ {
-			expr := statement.Expressions[i]
-			compiledExpr, err := compileExpression(cl, expr, context)
+	structName := inferReturnStructName(expr.ContextFunction.FnName)
+	content := indent(cl) + "struct " + structName + " " + temporary + " = " + compiledExpr + ";"
 
-			if err != nil {
-				return "", err
-			}
+	constant := ""
 
-			args += compiledExpr
+	if statement.Constant {
+		constant = "const "
+	}
 
-			if argCount > 0 && i != argCount-1 {
-				args += ", "
-			}
+	for i, identifier := range statement.Identifiers {
+		varType := statement.Types[i]
+
+		if varType.Id == parser.Bool {
+			importBoolean(cl)
 		}
 
-		return indent(cl) + statement.Value + "(" + args + ");", nil
+		content += "\n" + indent(cl) + constant + getTypeOfC(cl, varType) + " " + identifier.Value + fmt.Sprintf(" = %s.type%d;", temporary, i)
 	}
 
-	return indent(cl) + fmt.Sprintf("// UNKNOWN EXPRESSION %v", statement), nil
+	return content, nil
 }
 
-func compileBinaryExpression(cl *compiler, statement *parser.Statement, i int, context *parser.Scope) (string, error) {
-	left := statement.Left
-	right := statement.Right
-	operator := statement.Operator
-
-	content := ""
-
-	prioritized := operator != parser.AdditionOperation && operator != parser.SubtractionOperation
+// Number literals are decimal, suffixed ones are cast to their type
+func compileNumber(statement *parser.Statement) string {
+	value := statement.Value
 
-	if i > 0 && !prioritized {
-		content += "("
+	if statement.Range != "" {
+		return fmt.Sprintf("((%s)%s)", internalTypes[parser.NumberSuffixes[statement.Range]], value)
 	}
 
-	if left.Type == parser.BinaryExpression {
-		compiled, err := compileBinaryExpression(cl, left, i+1, context)
-
-		if err != nil {
-			return "", nil
-		}
-
-		content += compiled
-	} else {
-		compiled, err := compile(cl, left, context)
+	if strings.ContainsAny(value, ".eE") {
+		return value
+	}
 
-		if err != nil {
-			return "", nil
-		}
+	// C has no negative literals, the smallest int64 would overflow before negation
+	if value == "-9223372036854775808" {
+		return "INT64_MIN"
+	}
 
-		content += compiled
+	// Literals bigger than int64 are uint64
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return value + "ULL"
 	}
 
-	switch operator {
-	case parser.AdditionOperation:
-		content += "+"
-	case parser.SubtractionOperation:
-		content += "-"
-	case parser.MultiplicationOperation:
-		content += "*"
-	case parser.DivisionOperation:
-		content += "/"
-	case parser.ModulusOperation:
-		content += "%"
+	return value
+}
+
+func compileExpression(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	if statement.Type == parser.NumberLiteral {
+		return compileNumber(statement), nil
 	}
 
-	if right.Type == parser.BinaryExpression {
-		compiled, err := compileBinaryExpression(cl, right, i+1, context)
+	if statement.Type == parser.IdentifierExpression {
+		variable := context.GetVariable(statement.Value)
 
-		if err != nil {
-			return "", nil
+		if variable != nil && isScalarBool(variable.VarType) {
+			return statement.Value + ".value", nil
 		}
 
-		content += compiled
-	} else {
-		compiled, err := compile(cl, right, context)
+		return statement.Value, nil
+	}
+
+	if statement.Type == parser.BinaryExpression && isFresh(statement) {
+		concatenation, err := compileFresh(cl, statement, context)
 
 		if err != nil {
-			return "", nil
+			return "", err
 		}
 
-		content += compiled
+		return compileTemporary(cl, concatenation, statement.ArgTypes[0]), nil
 	}
 
-	if i > 0 && !prioritized {
-		content += ")"
+	if statement.Type == parser.BinaryExpression && isStringOperation(statement) {
+		return compileStringOperation(cl, statement, context)
 	}
 
-	return content, nil
-}
+	if statement.Type == parser.BinaryExpression {
+		return compileBinaryExpression(cl, statement, 0, context)
+	}
 
-func compileFunction(cl *compiler, statement *parser.Statement) (string, error) {
-	importBooleanIfNeeded(cl, *statement)
+	if statement.Type == parser.ConversionExpression && isFresh(statement) {
+		formatted, err := compileFresh(cl, statement, context)
 
-	if statement.Native {
-		if len(statement.Types) > 1 {
-			return "", compileError(*statement, "Native function can only return one value")
+		if err != nil {
+			return "", err
 		}
 
-		return "", nil
+		return compileTemporary(cl, formatted, statement.Types[0]), nil
 	}
 
-	content := ""
-
-	functionName := statement.Value
-	returnTypeC := "void"
-
-	typeCount := len(statement.Types)
+	if statement.Type == parser.ConversionExpression {
+		return compileConversion(cl, statement, context)
+	}
 
-	if typeCount > 1 {
-		returnTypeC = ""
+	if statement.Type == parser.FieldExpression && statement.ContextType.IsEnum() {
+		return compileVariant(cl, statement, context)
+	}
 
-		// Build a struct for return
-		structName := inferReturnStructName(functionName)
-		returnTypeC = "struct " + structName
+	if statement.Type == parser.FieldExpression {
+		object, err := compileExpression(cl, statement.Left, context)
 
-		returnStruct := "struct " + structName + " {\n"
+		if err != nil {
+			return "", err
+		}
 
-		cl.indent++
-		for i := 0; i < typeCount; i++ {
-			returnType := statement.Types[i]
-			cType := getTypeOfC(returnType)
+		field := object + "." + statement.Value
 
-			returnStruct += indent(cl) + fmt.Sprintf("%s type%d;\n", cType, i)
+		if fieldType, _ := statement.ContextType.GetField(statement.Value); isScalarBool(fieldType) {
+			return field + ".value", nil
 		}
-		cl.indent--
 
-		returnStruct += "};\n"
+		return field, nil
+	}
 
-		cl.prepend += returnStruct
+	if statement.Type == parser.IndexExpression {
+		return compileIndexExpression(cl, statement, context)
 	}
 
-	if typeCount == 1 {
-		returnTypeC = getTypeOfC(statement.Types[0])
+	if statement.Type == parser.SliceExpression {
+		return compileSliceExpression(cl, statement, context)
 	}
 
-	content += indent(cl) + returnTypeC + " " + functionName + "("
+	if statement.Type == parser.ArrayLiteral {
+		values, err := compileArrayLiteral(cl, statement, context)
 
-	argCount := len(statement.ArgTypes)
+		if err != nil {
+			return "", err
+		}
 
-	for i := 0; i < argCount; i++ {
-		abstractArgType := statement.ArgTypes[i]
-		argType := getTypeOfC(abstractArgType)
-		argName := statement.ArgNames[i]
+		arrayType := statement.Types[0]
 
-		content += argType + " " + argName
+		return "(" + getTypeOfC(cl, arrayType) + getArraySizesOfC(arrayType) + ")" + values, nil
+	}
 
-		if i != argCount-1 {
-			content += ", "
-		}
+	if statement.Type == parser.StructLiteral {
+		return compileStructLiteral(cl, statement, context)
 	}
 
-	content += ") "
+	if statement.Type == parser.UnaryExpression {
+		operand, err := compileExpression(cl, statement.Right, context)
 
-	scope := statement.RunScope
+		if err != nil {
+			return "", err
+		}
 
-	if scope == nil {
-		cl.indent++
-		content += " {\n" + indent(cl) + "// NO RUN SCOPE\n}\n"
-		cl.indent--
-		return content, nil
+		switch statement.Unary {
+		case parser.NotOperation:
+			return "!(" + operand + ")", nil
+		case parser.NegateOperation:
+			return "-(" + operand + ")", nil
+		case parser.BitwiseNotOperation:
+			return "~(" + operand + ")", nil
+		}
 	}
 
-	compiled, err := compileScope(cl, scope)
-
-	if err != nil {
-		return "", err
+	if statement.Type == parser.BooleanLiteral {
+		if statement.Value == "true" {
+			return "1", nil
+		}
+		return "0", nil
 	}
 
-	content += compiled
-
-	return content, nil
-}
+	if statement.Type == parser.StringLiteral {
+		return compileStringLiteral(cl, statement), nil
+	}
 
-func compileScope(cl *compiler, statement *parser.Statement) (string, error) {
-	content := ""
+	// Code points are numbers in C, strings hold their UTF-8
+	if statement.Type == parser.CharacterLiteral {
+		codePoint, _ := utf8.DecodeRuneInString(statement.Value)
 
-	if statement.Type == parser.ScopeDeclaration {
-		content += indent(cl) + "{\n"
+		return fmt.Sprintf("((uint32_t)0x%X)", codePoint), nil
 	}
 
-	cl.indent++
+	if statement.Type == parser.FunctionExpression && statement.ContextFunction.FnBuiltin {
+		return compileBuiltinExpression(cl, statement, context)
+	}
 
-	for _, child := range statement.Children {
-		code, err := compile(cl, child, &statement.Context)
+	if statement.Type == parser.FunctionExpression {
+		call, err := compileCall(cl, statement, context)
 
 		if err != nil {
 			return "", err
 		}
 
-		if len(code) > 0 {
-			content += code + "\n"
+		function := statement.ContextFunction
+
+		if isFresh(statement) {
+			return compileTemporary(cl, call, function.FnTypes[0]), nil
 		}
-	}
 
-	cl.indent--
+		// Strings of native functions are viewed until \0
+		if function.FnNative && len(function.FnTypes) == 1 && isString(function.FnTypes[0]) {
+			return importString(cl) + "_of(" + call + ")", nil
+		}
 
-	if statement.Type == parser.ScopeDeclaration {
-		content += indent(cl) + "}\n"
+		return call, nil
 	}
 
-	return content, nil
+	return fmt.Sprintf("/* UNKNOWN EXPRESSION %v */", statement), nil
 }
 
-func indent(cl *compiler) string {
-	str := ""
-	for j := 0; j < cl.indent; j++ {
-		str += "    "
-	}
-	return str
+// Compiles call of function, values returned are not registered as temporaries
+func compileCall(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	{
+		args := ""
+
+		argCount := len(statement.Expressions)
+		for i := 0; i < argCount; i++ {
+			expr := statement.Expressions[i]
+			compiledExpr, err := compileArgument(cl, statement, i, expr, context)
+
+			if err != nil {
+				return "", err
+			}
+
+			args += compiledExpr
+
+			if argCount > 0 && i != argCount-1 {
+				args += ", "
+			}
+		}
+
+		call := statement.Value + "(" + args + ")"
+
+		// Unwrap returned boolean
+		function := statement.ContextFunction
+		if function != nil && len(function.FnTypes) == 1 && function.FnTypes[0].Id == parser.Bool {
+			call += ".value"
+		}
+
+		return call, nil
+	}
+}
+
+// Compiles argument i of a call to function
+func compileArgument(cl *compiler, statement *parser.Statement, i int, expr *parser.Statement, context *parser.Scope) (string, error) {
+	function := statement.ContextFunction
+
+	if function == nil {
+		return compileExpression(cl, expr, context)
+	}
+
+	// Native functions take strings as char*
+	if function.FnNative && isString(statement.ArgTypes[i]) {
+		if expr.Type == parser.StringLiteral {
+			return quoteOfC(expr.Value), nil
+		}
+
+		compiled, err := compileExpression(cl, expr, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		return importString(cl) + "_c(" + compiled + ")", nil
+	}
+
+	argTypes := function.FnArgTypes
+	argCount := len(argTypes)
+
+	// Array literals are passed as compound literal
+	if argCount == 0 || expr.Type == parser.ArrayLiteral {
+		return compileExpression(cl, expr, context)
+	}
+
+	if i >= argCount {
+		i = argCount - 1
+	}
+
+	return compileValue(cl, expr, argTypes[i], context)
+}
+
+// Compiles expression as value of type, booleans are wrapped in their struct
+func compileValue(cl *compiler, expr *parser.Statement, aType parser.ActualType, context *parser.Scope) (string, error) {
+	// Nested in an initializer
+	if expr.Type == parser.ArrayLiteral {
+		return compileArrayLiteral(cl, expr, context)
+	}
+
+	compiled, err := compileExpression(cl, expr, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	if isScalarBool(aType) && !aType.Variadic {
+		importBoolean(cl)
+		return "(" + inferBoolean() + "){ " + compiled + " }", nil
+	}
+
+	return compiled, nil
+}
+
+func compileReturn(cl *compiler, statement *parser.Statement) (string, error) {
+	values := statement.Expressions
+	valueCount := len(values)
+
+	function := statement.ContextFunction
+
+	if valueCount == 0 {
+		if function.FnName == "main" {
+			return compileExit(cl, statement, "return 0;")
+		}
+
+		return compileExit(cl, statement, "return;")
+	}
+
+	if valueCount == 1 {
+		compiled, err := compileReturnValue(cl, values[0], function.FnTypes[0], &statement.Context, map[string]bool{})
+
+		if err != nil {
+			return "", err
+		}
+
+		return compileReturnOf(cl, statement, compiled, getTypeOfC(cl, function.FnTypes[0]))
+	}
+
+	// Populate return struct
+	fields := ""
+	moved := map[string]bool{}
+
+	for i := 0; i < valueCount; i++ {
+		compiled, err := compileReturnValue(cl, values[i], function.FnTypes[i], &statement.Context, moved)
+
+		if err != nil {
+			return "", err
+		}
+
+		fields += fmt.Sprintf(".type%d = %s", i, compiled)
+
+		if i != valueCount-1 {
+			fields += ", "
+		}
+	}
+
+	returnStruct := "struct " + inferReturnStructName(function.FnName)
+
+	return compileReturnOf(cl, statement, "("+returnStruct+"){ "+fields+" }", returnStruct)
+}
+
+// Temporaries of the returned value and the variables left are freed once it is computed
+func compileReturnOf(cl *compiler, statement *parser.Statement, value string, cType string) (string, error) {
+	cl.indent++
+	cleanup, err := compileCleanup(cl, statement)
+	cl.indent--
+
+	if err != nil {
+		return "", err
+	}
+
+	if !cl.temporariesUsed && cleanup == "" {
+		return indent(cl) + "return " + value + ";", nil
+	}
+
+	content := indent(cl) + "{\n"
+	cl.indent++
+	content += indent(cl) + cType + " " + inferName("result") + " = " + value + ";\n"
+
+	if cl.temporariesUsed {
+		cl.temporariesUsed = false
+		content += indent(cl) + freeTemporaries(cl) + "\n"
+	}
+
+	content += cleanup
+	content += indent(cl) + "return " + inferName("result") + ";\n"
+	cl.indent--
+
+	return content + indent(cl) + "}", nil
+}
+
+// C operators of binary operations
+var binaryOperators = map[parser.BinaryOperation]string{
+	parser.AdditionOperation:       "+",
+	parser.SubtractionOperation:    "-",
+	parser.MultiplicationOperation: "*",
+	parser.DivisionOperation:       "/",
+	parser.ModulusOperation:        "%",
+	parser.EqualsOperation:         "==",
+	parser.NotEqualsOperation:      "!=",
+	parser.SmallerOperation:        "<",
+	parser.SmallerEqualsOperation:  "<=",
+	parser.BiggerOperation:         ">",
+	parser.BiggerEqualsOperation:   ">=",
+	parser.AndOperation:            "&&",
+	parser.OrOperation:             "||",
+	parser.BitwiseAndOperation:     "&",
+	parser.BitwiseOrOperation:      "|",
+	parser.BitwiseXorOperation:     "^",
+	parser.ShiftLeftOperation:      "<<",
+	parser.ShiftRightOperation:     ">>",
+}
+
+// Slices and strings of local variables are handed over to the caller, each only once
+func compileReturnValue(cl *compiler, expr *parser.Statement, aType parser.ActualType, context *parser.Scope, moved map[string]bool) (string, error) {
+	if !isOwning(aType) {
+		return compileValue(cl, expr, aType, context)
+	}
+
+	if variable := context.GetVariable(expr.Value); expr.Type == parser.IdentifierExpression && !variable.VarOfFunction && !moved[expr.Value] {
+		moved[expr.Value] = true
+		return expr.Value, nil
+	}
+
+	return compileOwned(cl, expr, aType, context)
+}
+
+func compileBinaryExpression(cl *compiler, statement *parser.Statement, i int, context *parser.Scope) (string, error) {
+	left := statement.Left
+	right := statement.Right
+	operator := statement.Operator
+
+	content := ""
+
+	// Nested binary expressions are always put in parenthesis to keep their precedence
+	if i > 0 {
+		content += "("
+	}
+
+	if left.Type == parser.BinaryExpression && !isStringOperation(left) {
+		compiled, err := compileBinaryExpression(cl, left, i+1, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += compiled
+	} else {
+		compiled, err := compileExpression(cl, left, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += compiled
+	}
+
+	content += " " + binaryOperators[operator] + " "
+
+	if right.Type == parser.BinaryExpression && !isStringOperation(right) {
+		compiled, err := compileBinaryExpression(cl, right, i+1, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += compiled
+	} else {
+		compiled, err := compileExpression(cl, right, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += compiled
+	}
+
+	if i > 0 {
+		content += ")"
+	}
+
+	return content, nil
+}
+
+func compileFunction(cl *compiler, statement *parser.Statement) (string, error) {
+	importBooleanIfNeeded(cl, *statement)
+
+	if statement.Native {
+		if len(statement.Types) > 1 {
+			return "", compileError(*statement, "Native function can only return one value")
+		}
+
+		return "", nil
+	}
+
+	content := ""
+
+	functionName := statement.Value
+	returnTypeC := "void"
+
+	typeCount := len(statement.Types)
+
+	if typeCount > 1 {
+		returnTypeC = ""
+
+		// Build a struct for return
+		structName := inferReturnStructName(functionName)
+		returnTypeC = "struct " + structName
+
+		returnStruct := "struct " + structName + " {\n"
+
+		cl.indent++
+		for i := 0; i < typeCount; i++ {
+			returnType := statement.Types[i]
+			cType := getTypeOfC(cl, returnType)
+
+			returnStruct += indent(cl) + fmt.Sprintf("%s type%d;\n", cType, i)
+		}
+		cl.indent--
+
+		returnStruct += "};\n"
+
+		cl.prepend += returnStruct
+	}
+
+	if typeCount == 1 {
+		returnTypeC = getTypeOfC(cl, statement.Types[0])
+	}
+
+	// Void main exits with 0
+	if functionName == "main" && returnTypeC == "void" {
+		returnTypeC = "int"
+	}
+
+	cl.options.Debugf("compiler: function %s returns %s", functionName, returnTypeC)
+
+	content += indent(cl) + returnTypeC + " " + functionName + "("
+
+	argCount := len(statement.ArgTypes)
+
+	for i := 0; i < argCount; i++ {
+		abstractArgType := statement.ArgTypes[i]
+		argType := getTypeOfC(cl, abstractArgType)
+		argName := statement.ArgNames[i]
+
+		content += argType + " " + argName + getArraySizesOfC(abstractArgType)
+
+		if i != argCount-1 {
+			content += ", "
+		}
+	}
+
+	content += ") "
+
+	scope := statement.RunScope
+
+	if scope == nil {
+		cl.indent++
+		content += " {\n" + indent(cl) + "// NO RUN SCOPE\n}\n"
+		cl.indent--
+		return content, nil
+	}
+
+	compiled, err := compileScope(cl, scope)
+
+	if err != nil {
+		return "", err
+	}
+
+	content += markTemporaries(cl, compiled)
+
+	return content, nil
+}
+
+func compileConditional(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	condition, err := compileExpression(cl, statement.Condition, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	content := indent(cl)
+
+	for {
+		block, err := compileBlock(cl, statement.RunScope)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += "if (" + condition + ") " + block
+
+		elseScope := statement.ElseScope
+
+		if elseScope == nil {
+			return content, nil
+		}
+
+		content += " else "
+
+		if elseScope.Type != parser.ConditionalStatement {
+			block, err := compileBlock(cl, elseScope)
+
+			if err != nil {
+				return "", err
+			}
+
+			return content + block, nil
+		}
+
+		// Chain else if
+		statement = elseScope
+		condition, err = compileExpression(cl, statement.Condition, &statement.Context)
+
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func compileLoop(cl *compiler, statement *parser.Statement) (string, error) {
+	target := &breakTarget{loop: true}
+	cl.breakTargets = append(cl.breakTargets, target)
+
+	content, err := compileLoopStatement(cl, statement)
+
+	cl.breakTargets = cl.breakTargets[:len(cl.breakTargets)-1]
+
+	if err != nil {
+		return "", err
+	}
+
+	if target.label != "" {
+		content += "\n" + indent(cl) + target.label + ": ;"
+	}
+
+	return content, nil
+}
+
+// C break inside a switch leaves the switch, so breaking the loop around it jumps behind the loop
+func compileBreak(cl *compiler) string {
+	for i := len(cl.breakTargets) - 1; i >= 0; i-- {
+		target := cl.breakTargets[i]
+
+		if !target.loop {
+			continue
+		}
+
+		if i == len(cl.breakTargets)-1 {
+			break
+		}
+
+		if target.label == "" {
+			target.label = inferName(fmt.Sprintf("break%d", cl.temporaries))
+			cl.temporaries++
+		}
+
+		return "goto " + target.label + ";"
+	}
+
+	return "break;"
+}
+
+func compileLoopStatement(cl *compiler, statement *parser.Statement) (string, error) {
+	context := &statement.Context
+
+	condition := ""
+
+	if statement.Condition != nil {
+		compiled, err := compileExpression(cl, statement.Condition, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		condition = compiled
+	}
+
+	// Temporaries of the previous condition are freed each iteration
+	var prologue []string
+
+	if statement.Right != nil {
+		return compileForIn(cl, statement)
+	}
+
+	if statement.Initializer == nil && statement.Step == nil && statement.Condition != nil {
+		if cl.temporariesUsed {
+			prologue = append(prologue, freeTemporaries(cl))
+		}
+
+		block, err := compileBlockWith(cl, statement.RunScope, prologue)
+
+		if err != nil {
+			return "", err
+		}
+
+		return indent(cl) + "while (" + condition + ") " + block, nil
+	}
+
+	content := ""
+
+	// Initializer is placed in a surrounding block to scope its variables
+	if statement.Initializer != nil {
+		content += indent(cl) + "{\n"
+		cl.indent++
+
+		initializer, err := compile(cl, statement.Initializer, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += initializer + "\n"
+	}
+
+	step := ""
+
+	if statement.Step != nil {
+		// Compile without indent, multiple assignments are joined by ,
+		previousIndent := cl.indent
+		cl.indent = 0
+		compiled, err := compile(cl, statement.Step, context)
+		cl.indent = previousIndent
+
+		if err != nil {
+			return "", err
+		}
+
+		step = strings.ReplaceAll(strings.TrimSuffix(compiled, ";"), ";\n", ", ")
+	}
+
+	if cl.temporariesUsed {
+		prologue = append(prologue, freeTemporaries(cl))
+	}
+
+	block, err := compileBlockWith(cl, statement.RunScope, prologue)
+
+	if err != nil {
+		return "", err
+	}
+
+	content += indent(cl) + "for (; " + condition + "; " + step + ") " + block
+
+	if statement.Initializer != nil {
+		cl.indent--
+		content += "\n" + indent(cl) + "}"
+	}
+
+	return content, nil
+}
+
+// Compiles a scope to { ... } without leading indent or trailing line feed
+func compileBlock(cl *compiler, statement *parser.Statement) (string, error) {
+	return compileBlockWith(cl, statement, nil)
+}
+
+// Compiles a scope to { ... }, lines are placed before its statements
+func compileBlockWith(cl *compiler, statement *parser.Statement, lines []string) (string, error) {
+	content := "{\n"
+
+	// Temporaries of the statement holding the block are freed after it
+	temporariesUsed := cl.temporariesUsed
+
+	cl.indent++
+
+	for _, line := range lines {
+		content += indent(cl) + line + "\n"
+	}
+
+	for _, child := range statement.Children {
+		cl.temporariesUsed = false
+
+		code, err := compileWithLine(cl, child, &statement.Context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += code
+
+		if cl.temporariesUsed {
+			content += indent(cl) + freeTemporaries(cl) + "\n"
+		}
+	}
+
+	cl.indent--
+
+	cl.temporariesUsed = temporariesUsed
+
+	return content + indent(cl) + "}", nil
+}
+
+func compileScope(cl *compiler, statement *parser.Statement) (string, error) {
+	if statement.Type == parser.ScopeDeclaration {
+		block, err := compileBlock(cl, statement)
+
+		if err != nil {
+			return "", err
+		}
+
+		return indent(cl) + block + "\n", nil
+	}
+
+	content := ""
+
+	cl.indent++
+
+	for _, child := range statement.Children {
+		code, err := compileWithLine(cl, child, &statement.Context)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += code
+	}
+
+	cl.indent--
+
+	return content, nil
+}
+
+// Compiles statement preceded by the #line directive of its source, empty if no code is generated
+func compileWithLine(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	line, file := cl.line, cl.file
+	directive := lineDirective(cl, statement)
+
+	code, err := compile(cl, statement, context)
+
+	if err != nil || len(code) == 0 {
+		cl.line, cl.file = line, file
+		return "", err
+	}
+
+	return directive + code + "\n", nil
+}
+
+// Maps following C lines back to the source file and line of statement,
+// so C compiler diagnostics point to the original source
+func lineDirective(cl *compiler, statement *parser.Statement) string {
+	trace := statement.Trace
+
+	if trace.Row <= 0 || (trace.Row == cl.line && trace.File == cl.file) {
+		return ""
+	}
+
+	cl.line = trace.Row
+
+	if trace.File == cl.file {
+		return fmt.Sprintf("#line %d\n", trace.Row)
+	}
+
+	cl.file = trace.File
+
+	return fmt.Sprintf("#line %d %s\n", trace.Row, quoteOfC(trace.File))
+}
+
+func indent(cl *compiler) string {
+	str := ""
+	for j := 0; j < cl.indent; j++ {
+		str += "    "
+	}
+	return str
+}
+
+// Struct types are declared in head, before functions using them
+func compileStructDeclaration(cl *compiler, statement *parser.Statement) (string, error) {
+	importBooleanIfNeeded(cl, *statement)
+
+	name := statement.Value
+	content := "typedef struct " + name + " {\n"
+
+	cl.indent++
+	for i, fieldName := range statement.ArgNames {
+		content += indent(cl) + getTypeOfC(cl, statement.ArgTypes[i]) + " " + fieldName + getArraySizesOfC(statement.ArgTypes[i]) + ";\n"
+	}
+	cl.indent--
+
+	content += "} " + name + ";\n"
+
+	cl.head += content
+
+	return "", nil
+}
+
+// Enums are a tag with a union of the variant payloads, declared in head
+func compileEnumDeclaration(cl *compiler, statement *parser.Statement) (string, error) {
+	name := statement.Value
+
+	content := "typedef enum " + name + "_Tag {\n"
+	for _, variant := range statement.Children {
+		content += "    " + variantTag(name, variant.Value) + ",\n"
+	}
+	content += "} " + name + "_Tag;\n"
+
+	payloads := ""
+	for _, variant := range statement.Children {
+		if len(variant.ArgTypes) == 0 {
+			continue
+		}
+
+		importBooleanIfNeeded(cl, *variant)
+
+		payloads += "        struct {"
+		for i, payloadType := range variant.ArgTypes {
+			payloads += fmt.Sprintf(" %s type%d;", getTypeOfC(cl, payloadType), i)
+		}
+		payloads += " } " + variant.Value + ";\n"
+	}
+
+	content += "typedef struct " + name + " {\n"
+	content += "    " + name + "_Tag tag;\n"
+
+	if payloads != "" {
+		content += "    union {\n" + payloads + "    } as;\n"
+	}
+
+	content += "} " + name + ";\n"
+
+	cl.head += content
+
+	return "", nil
+}
+
+func variantTag(enum string, variant string) string {
+	return enum + "_" + variant
+}
+
+// Compiles Enum.Variant(payload) to compound literal setting tag and payload
+func compileVariant(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	enumType := statement.ContextType
+	variant := enumType.GetVariant(statement.Value)
+	content := "(" + enumType.TypeName + "){ .tag = " + variantTag(enumType.TypeName, statement.Value)
+
+	if len(statement.Expressions) > 0 {
+		values := []string{}
+
+		for i, expr := range statement.Expressions {
+			value, err := compileValue(cl, expr, enumType.TypeVariantTypes[variant][i], context)
+
+			if err != nil {
+				return "", err
+			}
+
+			values = append(values, fmt.Sprintf(".type%d = %s", i, value))
+		}
+
+		content += ", .as." + statement.Value + " = { " + strings.Join(values, ", ") + " }"
+	}
+
+	return content + " }", nil
+}
+
+// Compiles match to switch on the tag, the payload is copied into the names of the arm
+func compileMatch(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	enumType := statement.ContextType
+
+	value, err := compileExpression(cl, statement.Condition, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	content := ""
+	subject := value
+
+	// Evaluate value once, arms read its payload
+	temporary := statement.Condition.Type != parser.IdentifierExpression
+	if temporary {
+		subject = inferName(fmt.Sprintf("match%d", cl.temporaries))
+		cl.temporaries++
+
+		content += indent(cl) + "{\n"
+		cl.indent++
+		content += indent(cl) + enumType.TypeName + " " + subject + " = " + value + ";\n"
+	}
+
+	content += indent(cl) + "switch (" + subject + ".tag) {\n"
+
+	cl.breakTargets = append(cl.breakTargets, &breakTarget{})
+
+	for _, arm := range statement.Children {
+		label := "default"
+		bindings := []string{}
+
+		if arm.Value != "" {
+			label = "case " + variantTag(enumType.TypeName, arm.Value)
+
+			for i, name := range arm.ArgNames {
+				bindings = append(bindings, fmt.Sprintf("%s %s = %s.as.%s.type%d;", getTypeOfC(cl, arm.ArgTypes[i]), name, subject, arm.Value, i))
+			}
+		}
+
+		block, err := compileBlockWith(cl, arm.RunScope, bindings)
+
+		if err != nil {
+			return "", err
+		}
+
+		content += indent(cl) + label + ": " + block + "\n"
+		content += indent(cl) + "break;\n"
+	}
+
+	cl.breakTargets = cl.breakTargets[:len(cl.breakTargets)-1]
+
+	content += indent(cl) + "}"
+
+	if temporary {
+		cl.indent--
+		content += "\n" + indent(cl) + "}"
+	}
+
+	return content, nil
+}
+
+// Compiles to compound literal with designated initializers, missing fields are zero
+func compileStructLiteral(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	fields := []string{}
+
+	for i, fieldName := range statement.ArgNames {
+		fieldType, _ := statement.ContextType.GetField(fieldName)
+		value, err := compileValue(cl, statement.Expressions[i], fieldType, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		fields = append(fields, "."+fieldName+" = "+value)
+	}
+
+	if len(fields) == 0 {
+		return "(" + statement.Value + "){ 0 }", nil
+	}
+
+	return "(" + statement.Value + "){ " + strings.Join(fields, ", ") + " }", nil
+}
+
+// Values of array literal as brace list: { a, b }
+func compileArrayLiteral(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	arrayType := statement.Types[0]
+	element := arrayType
+	element.ArraySizes = arrayType.ArraySizes[1:]
+
+	values := []string{}
+
+	for _, expr := range statement.Expressions {
+		value, err := compileValue(cl, expr, element, context)
+
+		if err != nil {
+			return "", err
+		}
+
+		values = append(values, value)
+	}
+
+	return "{ " + strings.Join(values, ", ") + " }", nil
+}
+
+func compileConversion(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	if statement.Types[0].Id == parser.Slice {
+		return compileArrayToSlice(cl, statement, context)
+	}
+
+	operand, err := compileExpression(cl, statement.Right, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	from, to := statement.ArgTypes[0], statement.Types[0]
+
+	switch {
+	case isString(to) && !isString(from):
+		return compileStringConversion(cl, from, operand), nil
+	case from.Id == to.Id:
+		return "(" + operand + ")", nil
+	case to.Id == parser.Bool:
+		return "((" + operand + ") != 0)", nil
+	case (from.Id == parser.Float32 || from.Id == parser.Float64) && to.Id != parser.Float32 && to.Id != parser.Float64 && to.Id != parser.Complex64 && to.Id != parser.Complex128:
+		return importFloatToInteger(cl, to) + "(" + operand + ")", nil
+	}
+
+	// The operand keeps its precedence: int32(a + b) * 10 casts the sum
+	return "((" + getTypeOfC(cl, to) + ")(" + operand + "))", nil
+}
+
+// Generates function converting floating point numbers to the integer type.
+// Out of range values saturate, NaN is 0 (a plain C cast is undefined for both).
+func importFloatToInteger(cl *compiler, to parser.ActualType) string {
+	cType := getTypeOfC(cl, to)
+	name := inferName("to_" + cType)
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return name
+		}
+	}
+
+	limit := strings.ToUpper(strings.TrimSuffix(cType, "_t"))
+	minimum := limit + "_MIN"
+
+	if strings.HasPrefix(limit, "U") {
+		minimum = "0"
+	}
+
+	cl.head += fmt.Sprintf("static inline %s %s(double x) {\n", cType, name)
+	cl.head += "    if (x != x) return 0;\n"
+	cl.head += fmt.Sprintf("    if (x <= (double)%s) return %s;\n", minimum, minimum)
+	cl.head += fmt.Sprintf("    if (x >= (double)%s_MAX) return %s_MAX;\n", limit, limit)
+	cl.head += fmt.Sprintf("    return (%s)x;\n}\n", cType)
+
+	cl.helpers = append(cl.helpers, name)
+
+	return name
 }
 
 func inferName(name string) string {
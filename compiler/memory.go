@@ -0,0 +1,123 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/yonedash/comet/parser"
+)
+
+// Slices and strings point to memory on the heap once stored in a variable,
+// the variable frees it when de-allocated. Values created by concatenation
+// or returned by functions are fresh and taken over when stored. Fresh values
+// that are only read are temporaries, freed after the statement using them.
+// Fields and elements never free their values, so they only hold literals.
+
+func isOwning(aType parser.ActualType) bool {
+	return (aType.Id == parser.Slice || aType.Id == parser.String) && len(aType.ArraySizes) == 0
+}
+
+// Checks if the expression creates a value nobody owns yet
+func isFresh(expr *parser.Statement) bool {
+	switch expr.Type {
+	case parser.FunctionExpression:
+		function := expr.ContextFunction
+		return !function.FnNative && !function.FnBuiltin && len(function.FnTypes) == 1 && isOwning(function.FnTypes[0])
+
+	case parser.BinaryExpression:
+		return isStringOperation(expr) && expr.Operator == parser.AdditionOperation
+
+	case parser.ConversionExpression:
+		return isString(expr.Types[0]) && !isString(expr.ArgTypes[0])
+	}
+
+	return false
+}
+
+// Compiles value stored in a variable or returned, fresh values are taken over and every other value is copied
+func compileOwned(cl *compiler, expr *parser.Statement, aType parser.ActualType, context *parser.Scope) (string, error) {
+	if isFresh(expr) {
+		return compileFresh(cl, expr, context)
+	}
+
+	compiled, err := compileExpression(cl, expr, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	return getTypeOfC(cl, aType) + "_copy(" + compiled + ")", nil
+}
+
+// Compiles fresh value without registering it as temporary
+func compileFresh(cl *compiler, expr *parser.Statement, context *parser.Scope) (string, error) {
+	if expr.Type == parser.FunctionExpression {
+		return compileCall(cl, expr, context)
+	}
+
+	if expr.Type == parser.ConversionExpression {
+		return compileConversion(cl, expr, context)
+	}
+
+	return compileStringOperation(cl, expr, context)
+}
+
+// Registers fresh value to be freed after the current statement
+func compileTemporary(cl *compiler, compiled string, aType parser.ActualType) string {
+	importTemporaries(cl)
+
+	cl.temporariesUsed = true
+
+	return getTypeOfC(cl, aType) + "_temporary(" + compiled + ")"
+}
+
+// Frees temporaries of the statement, the mark keeps temporaries of calling functions alive
+func freeTemporaries(cl *compiler) string {
+	cl.temporariesMarked = true
+
+	return inferName("free_temporaries") + "(" + inferName("mark") + ");"
+}
+
+// Declares the mark of the function body if its statements free temporaries
+func markTemporaries(cl *compiler, body string) string {
+	if !cl.temporariesMarked {
+		return body
+	}
+
+	cl.temporariesMarked = false
+
+	cl.indent++
+	mark := indent(cl) + fmt.Sprintf("int64_t %s = %s;\n", inferName("mark"), inferName("temporaries_len"))
+	cl.indent--
+
+	return "{\n" + mark + body[len("{\n"):]
+}
+
+// Generates the list of temporaries
+func importTemporaries(cl *compiler) {
+	name := inferName("temporaries")
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return
+		}
+	}
+
+	cl.cImportLib("stdlib.h")
+
+	cl.head += fmt.Sprintf("static void** %s = NULL;\n", name)
+	cl.head += fmt.Sprintf("static int64_t %s_len = 0;\n", name)
+	cl.head += fmt.Sprintf("static int64_t %s_cap = 0;\n", name)
+
+	cl.head += fmt.Sprintf("static void %s(void* data) {\n", inferName("temporary"))
+	cl.head += fmt.Sprintf("    if (%s_len == %s_cap) {\n", name, name)
+	cl.head += fmt.Sprintf("        %s_cap = %s_cap == 0 ? 16 : %s_cap * 2;\n", name, name, name)
+	cl.head += fmt.Sprintf("        %s = realloc(%s, %s_cap * sizeof(void*));\n", name, name, name)
+	cl.head += fmt.Sprintf("        if (%s == NULL) abort();\n", name)
+	cl.head += "    }\n"
+	cl.head += fmt.Sprintf("    %s[%s_len++] = data;\n}\n", name, name)
+
+	cl.head += fmt.Sprintf("static void %s(int64_t mark) {\n", inferName("free_temporaries"))
+	cl.head += fmt.Sprintf("    while (%s_len > mark) free(%s[--%s_len]);\n}\n", name, name, name)
+
+	cl.helpers = append(cl.helpers, name)
+}
@@ -0,0 +1,229 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yonedash/comet/parser"
+)
+
+// Slices are a pointer to their elements with length and capacity. Variables
+// own the elements of their slice and free them once de-allocated, every
+// other slice is a view that is copied when stored.
+
+// Generates the struct and runtime functions of slices of the element type, returns the struct name
+func importSlice(cl *compiler, element parser.ActualType) string {
+	cType := getTypeOfC(cl, element)
+
+	name := cType
+	switch element.Id {
+	case parser.Bool:
+		name = "bool"
+	case parser.String:
+		name = "string"
+	}
+
+	name = inferName("slice_" + strings.ReplaceAll(name, " ", "_"))
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return name
+		}
+	}
+
+	importSliceRuntime(cl)
+	importTemporaries(cl)
+
+	if element.Id == parser.Bool {
+		importBoolean(cl)
+	}
+
+	cl.head += fmt.Sprintf("typedef struct %s {\n    %s* data;\n    int64_t len;\n    int64_t cap;\n} %s;\n", name, cType, name)
+
+	cl.head += fmt.Sprintf("static inline %s %s_copy(%s s) {\n", name, name, name)
+	cl.head += fmt.Sprintf("    %s copy = { 0 };\n", name)
+	cl.head += "    if (s.len == 0) return copy;\n"
+	cl.head += fmt.Sprintf("    copy.data = malloc(s.len * sizeof(%s));\n", cType)
+	cl.head += "    if (copy.data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += fmt.Sprintf("    memcpy(copy.data, s.data, s.len * sizeof(%s));\n", cType)
+	cl.head += "    copy.len = copy.cap = s.len;\n"
+	cl.head += "    return copy;\n}\n"
+
+	cl.head += fmt.Sprintf("static inline void %s_append(%s* s, %s value) {\n", name, name, cType)
+	cl.head += "    if (s->len == s->cap) {\n"
+	cl.head += "        s->cap = s->cap == 0 ? 4 : s->cap * 2;\n"
+	cl.head += fmt.Sprintf("        s->data = realloc(s->data, s->cap * sizeof(%s));\n", cType)
+	cl.head += "        if (s->data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += "    }\n"
+	cl.head += "    s->data[s->len++] = value;\n}\n"
+
+	cl.head += fmt.Sprintf("static inline %s* %s_at(%s s, int64_t index, const char* trace) {\n", cType, name, name)
+	cl.head += "    if (index < 0 || index >= s.len) " + inferName("index_out_of_bounds") + "(index, s.len, trace);\n"
+	cl.head += "    return &s.data[index];\n}\n"
+
+	cl.head += fmt.Sprintf("static inline %s %s_range(%s s, int64_t start, int64_t end, const char* trace) {\n", name, name, name)
+	cl.head += "    if (start < 0 || end < start || end > s.len) " + inferName("range_out_of_bounds") + "(start, end, s.len, trace);\n"
+	cl.head += fmt.Sprintf("    return (%s){ s.data + start, end - start, end - start };\n}\n", name)
+
+	cl.head += fmt.Sprintf("static inline %s %s_range_from(%s s, int64_t start, const char* trace) {\n", name, name, name)
+	cl.head += fmt.Sprintf("    return %s_range(s, start, s.len, trace);\n}\n", name)
+
+	cl.head += fmt.Sprintf("static inline void %s_set(%s* target, %s value) {\n", name, name, name)
+	cl.head += "    free(target->data);\n"
+	cl.head += "    *target = value;\n}\n"
+
+	cl.head += fmt.Sprintf("static inline %s %s_temporary(%s s) {\n", name, name, name)
+	cl.head += "    " + inferName("temporary") + "(s.data);\n"
+	cl.head += "    return s;\n}\n"
+
+	cl.helpers = append(cl.helpers, name)
+
+	return name
+}
+
+// Generates the functions aborting on invalid access, shared by all slices and strings
+func importSliceRuntime(cl *compiler) {
+	name := inferName("index_out_of_bounds")
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return
+		}
+	}
+
+	cl.cImportLib("stdio.h")
+	cl.cImportLib("stdlib.h")
+	cl.cImportLib("string.h")
+
+	cl.head += fmt.Sprintf("static void %s(int64_t index, int64_t len, const char* trace) {\n", name)
+	cl.head += "    fflush(stdout);\n"
+	cl.head += "    fprintf(stderr, \"index %lld out of bounds for length %lld @ %s\\n\", (long long)index, (long long)len, trace);\n"
+	cl.head += "    abort();\n}\n"
+
+	cl.head += fmt.Sprintf("static void %s(int64_t start, int64_t end, int64_t len, const char* trace) {\n", inferName("range_out_of_bounds"))
+	cl.head += "    fflush(stdout);\n"
+	cl.head += "    fprintf(stderr, \"range %lld:%lld out of bounds for length %lld @ %s\\n\", (long long)start, (long long)end, (long long)len, trace);\n"
+	cl.head += "    abort();\n}\n"
+
+	cl.head += fmt.Sprintf("static void %s(void) {\n", inferName("out_of_memory"))
+	cl.head += "    fprintf(stderr, \"out of memory\\n\");\n"
+	cl.head += "    abort();\n}\n"
+
+	cl.helpers = append(cl.helpers, name)
+}
+
+// Location of the expression passed to the runtime for aborts, file names may need escapes
+func traceOfC(statement *parser.Statement) string {
+	return quoteOfC(statement.Trace.String())
+}
+
+// Compiles element access, indices of slices and strings are checked at runtime
+func compileIndexExpression(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	array, err := compileExpression(cl, statement.Left, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	index, err := compileExpression(cl, statement.Right, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	element := array + "[" + index + "]"
+
+	indexed := statement.ArgTypes[0]
+
+	if indexed.Id == parser.Slice {
+		element = fmt.Sprintf("(*%s_at(%s, %s, %s))", importSlice(cl, *indexed.ElementType), array, index, traceOfC(statement))
+	}
+
+	// Bytes of strings are no lvalue, strings are immutable
+	if isString(indexed) {
+		return fmt.Sprintf("%s_at(%s, %s, %s)", importString(cl), array, index, traceOfC(statement)), nil
+	}
+
+	if isScalarBool(statement.Types[0]) {
+		return element + ".value", nil
+	}
+
+	return element, nil
+}
+
+// Compiles view of the elements or bytes between start and end: a[start:end]
+func compileSliceExpression(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	sliced, err := compileExpression(cl, statement.Left, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	sliceName := getTypeOfC(cl, statement.Types[0])
+
+	if slicedType := statement.ArgTypes[0]; len(slicedType.ArraySizes) > 0 {
+		size := slicedType.ArraySizes[0]
+		sliced = fmt.Sprintf("((%s){ %s, %d, %d })", sliceName, sliced, size, size)
+	}
+
+	bounds := []string{"0", ""}
+
+	for i, bound := range statement.Expressions {
+		if bound == nil {
+			continue
+		}
+
+		bounds[i], err = compileExpression(cl, bound, context)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if bounds[1] == "" {
+		return fmt.Sprintf("%s_range_from(%s, %s, %s)", sliceName, sliced, bounds[0], traceOfC(statement)), nil
+	}
+
+	return fmt.Sprintf("%s_range(%s, %s, %s, %s)", sliceName, sliced, bounds[0], bounds[1], traceOfC(statement)), nil
+}
+
+// Compiles len(value) and append(slice, element)
+func compileBuiltinExpression(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	function := statement.ContextFunction
+	valueType := function.FnArgTypes[0]
+
+	value, err := compileExpression(cl, statement.Expressions[0], context)
+
+	if err != nil {
+		return "", err
+	}
+
+	if function.FnName == "len" {
+		if len(valueType.ArraySizes) == 0 {
+			return value + ".len", nil
+		}
+
+		return fmt.Sprintf("((int64_t)%d)", valueType.ArraySizes[0]), nil
+	}
+
+	element, err := compileValue(cl, statement.Expressions[1], function.FnArgTypes[1], context)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s_append(&%s, %s)", importSlice(cl, *valueType.ElementType), value, element), nil
+}
+
+// Views the elements of the array as slice
+func compileArrayToSlice(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	operand, err := compileExpression(cl, statement.Right, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	size := statement.ArgTypes[0].ArraySizes[0]
+
+	return fmt.Sprintf("((%s){ %s, %d, %d })", getTypeOfC(cl, statement.Types[0]), operand, size, size), nil
+}
@@ -0,0 +1,313 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/yonedash/comet/parser"
+)
+
+// Strings are bytes with length. The bytes of strings owned by a variable are
+// followed by \0, views of literals and substrings are only valid while the
+// string they are taken from is.
+
+// Generates the string struct and its runtime functions, returns the struct name
+func importString(cl *compiler) string {
+	name := inferName("string")
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return name
+		}
+	}
+
+	importSliceRuntime(cl)
+	importTemporaries(cl)
+
+	cl.head += fmt.Sprintf("typedef struct %s {\n    char* data;\n    int64_t len;\n} %s;\n", name, name)
+
+	cl.head += fmt.Sprintf("static %s %s_copy(%s s) {\n", name, name, name)
+	cl.head += "    char* data = malloc(s.len + 1);\n"
+	cl.head += "    if (data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += "    if (s.len > 0) memcpy(data, s.data, s.len);\n"
+	cl.head += "    data[s.len] = '\\0';\n"
+	cl.head += fmt.Sprintf("    return (%s){ data, s.len };\n}\n", name)
+
+	cl.head += fmt.Sprintf("static %s %s_concat(%s a, %s b) {\n", name, name, name, name)
+	cl.head += "    char* data = malloc(a.len + b.len + 1);\n"
+	cl.head += "    if (data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += "    if (a.len > 0) memcpy(data, a.data, a.len);\n"
+	cl.head += "    if (b.len > 0) memcpy(data + a.len, b.data, b.len);\n"
+	cl.head += "    data[a.len + b.len] = '\\0';\n"
+	cl.head += fmt.Sprintf("    return (%s){ data, a.len + b.len };\n}\n", name)
+
+	cl.head += fmt.Sprintf("static inline int %s_equals(%s a, %s b) {\n", name, name, name)
+	cl.head += "    return a.len == b.len && (a.len == 0 || memcmp(a.data, b.data, a.len) == 0);\n}\n"
+
+	cl.head += fmt.Sprintf("static inline uint8_t %s_at(%s s, int64_t index, const char* trace) {\n", name, name)
+	cl.head += "    if (index < 0 || index >= s.len) " + inferName("index_out_of_bounds") + "(index, s.len, trace);\n"
+	cl.head += "    return (uint8_t)s.data[index];\n}\n"
+
+	cl.head += fmt.Sprintf("static inline %s %s_range(%s s, int64_t start, int64_t end, const char* trace) {\n", name, name, name)
+	cl.head += "    if (start < 0 || end < start || end > s.len) " + inferName("range_out_of_bounds") + "(start, end, s.len, trace);\n"
+	cl.head += fmt.Sprintf("    return (%s){ s.data + start, end - start };\n}\n", name)
+
+	cl.head += fmt.Sprintf("static inline %s %s_range_from(%s s, int64_t start, const char* trace) {\n", name, name, name)
+	cl.head += fmt.Sprintf("    return %s_range(s, start, s.len, trace);\n}\n", name)
+
+	cl.head += fmt.Sprintf("static inline void %s_set(%s* target, %s value) {\n", name, name, name)
+	cl.head += "    free(target->data);\n"
+	cl.head += "    *target = value;\n}\n"
+
+	cl.head += fmt.Sprintf("static inline %s %s_temporary(%s s) {\n", name, name, name)
+	cl.head += "    " + inferName("temporary") + "(s.data);\n"
+	cl.head += "    return s;\n}\n"
+
+	// Native functions expect \0 after the bytes
+	cl.head += fmt.Sprintf("static char* %s_c(%s s) {\n", name, name)
+	cl.head += "    if (s.data == NULL) return \"\";\n"
+	cl.head += "    if (s.data[s.len] == '\\0') return s.data;\n"
+	cl.head += fmt.Sprintf("    return %s_temporary(%s_copy(s)).data;\n}\n", name, name)
+
+	cl.head += fmt.Sprintf("static inline %s %s_of(char* data) {\n", name, name)
+	cl.head += fmt.Sprintf("    return (%s){ data, data == NULL ? 0 : (int64_t)strlen(data) };\n}\n", name)
+
+	cl.helpers = append(cl.helpers, name)
+
+	return name
+}
+
+// Generates functions formatting numbers and bool as string
+func importStringFormat(cl *compiler) string {
+	name := inferName("string_format")
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return name
+		}
+	}
+
+	stringName := importString(cl)
+	cl.cImportLib("stdarg.h")
+
+	cl.head += fmt.Sprintf("static %s %s(const char* format, ...) {\n", stringName, name)
+	cl.head += "    va_list args;\n"
+	cl.head += "    va_start(args, format);\n"
+	cl.head += "    int len = vsnprintf(NULL, 0, format, args);\n"
+	cl.head += "    va_end(args);\n"
+	cl.head += "    char* data = malloc(len + 1);\n"
+	cl.head += "    if (data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += "    va_start(args, format);\n"
+	cl.head += "    vsnprintf(data, len + 1, format, args);\n"
+	cl.head += "    va_end(args);\n"
+	cl.head += fmt.Sprintf("    return (%s){ data, len };\n}\n", stringName)
+
+	// Shortest digits parsed back to the same number
+	cl.head += fmt.Sprintf("static %s %s_float(double x, int single) {\n", stringName, name)
+	cl.head += "    char buffer[32];\n"
+	cl.head += "    for (int digits = 1; digits <= 17; digits++) {\n"
+	cl.head += "        snprintf(buffer, sizeof(buffer), \"%.*g\", digits, x);\n"
+	cl.head += "        double parsed = strtod(buffer, NULL);\n"
+	cl.head += "        if (single ? (float)parsed == (float)x : parsed == x) break;\n"
+	cl.head += "    }\n"
+	cl.head += fmt.Sprintf("    return %s(\"%%s\", buffer);\n}\n", name)
+
+	cl.helpers = append(cl.helpers, name)
+
+	return name
+}
+
+// Compiles formatting of the number or bool operand as new string
+func compileStringConversion(cl *compiler, from parser.ActualType, operand string) string {
+	format := importStringFormat(cl)
+
+	switch from.Id {
+	case parser.Bool:
+		return fmt.Sprintf("%s(\"%%s\", (%s) ? \"true\" : \"false\")", format, operand)
+	case parser.Rune:
+		return fmt.Sprintf("%s(%s)", importRunes(cl)+"_encode", operand)
+	case parser.Float32:
+		return fmt.Sprintf("%s_float(%s, 1)", format, operand)
+	case parser.Float64:
+		return fmt.Sprintf("%s_float(%s, 0)", format, operand)
+	case parser.UnsignedInt8, parser.UnsignedInt16, parser.UnsignedInt32, parser.UnsignedInt64:
+		return fmt.Sprintf("%s(\"%%llu\", (unsigned long long)(%s))", format, operand)
+	}
+
+	return fmt.Sprintf("%s(\"%%lld\", (long long)(%s))", format, operand)
+}
+
+// Generates functions decoding and encoding code points as UTF-8, returns their prefix.
+// Invalid bytes decode to U+FFFD one at a time and invalid code points encode as U+FFFD.
+func importRunes(cl *compiler) string {
+	name := inferName("rune")
+
+	for _, helper := range cl.helpers {
+		if helper == name {
+			return name
+		}
+	}
+
+	stringName := importString(cl)
+
+	cl.head += fmt.Sprintf("static uint32_t %s_decode(%s s, int64_t* offset) {\n", name, stringName)
+	cl.head += "    const unsigned char* p = (const unsigned char*)s.data + *offset;\n"
+	cl.head += "    uint32_t c = p[0];\n"
+	cl.head += "    int size;\n"
+	cl.head += "    uint32_t min;\n"
+	cl.head += "    if (c < 0x80) { *offset += 1; return c; }\n"
+	cl.head += "    else if (c >= 0xC2 && c <= 0xDF) { size = 2; c &= 0x1F; min = 0x80; }\n"
+	cl.head += "    else if (c >= 0xE0 && c <= 0xEF) { size = 3; c &= 0x0F; min = 0x800; }\n"
+	cl.head += "    else if (c >= 0xF0 && c <= 0xF4) { size = 4; c &= 0x07; min = 0x10000; }\n"
+	cl.head += "    else { *offset += 1; return 0xFFFD; }\n"
+	cl.head += "    if (s.len - *offset < size) { *offset += 1; return 0xFFFD; }\n"
+	cl.head += "    for (int i = 1; i < size; i++) {\n"
+	cl.head += "        if ((p[i] & 0xC0) != 0x80) { *offset += 1; return 0xFFFD; }\n"
+	cl.head += "        c = (c << 6) | (p[i] & 0x3F);\n"
+	cl.head += "    }\n"
+	cl.head += "    if (c < min || c > 0x10FFFF || (c >= 0xD800 && c <= 0xDFFF)) { *offset += 1; return 0xFFFD; }\n"
+	cl.head += "    *offset += size;\n"
+	cl.head += "    return c;\n}\n"
+
+	cl.head += fmt.Sprintf("static %s %s_encode(uint32_t c) {\n", stringName, name)
+	cl.head += "    char* data = malloc(5);\n"
+	cl.head += "    if (data == NULL) " + inferName("out_of_memory") + "();\n"
+	cl.head += "    if (c > 0x10FFFF || (c >= 0xD800 && c <= 0xDFFF)) c = 0xFFFD;\n"
+	cl.head += "    int64_t len;\n"
+	cl.head += "    if (c < 0x80) { data[0] = c; len = 1; }\n"
+	cl.head += "    else if (c < 0x800) { data[0] = 0xC0 | (c >> 6); data[1] = 0x80 | (c & 0x3F); len = 2; }\n"
+	cl.head += "    else if (c < 0x10000) { data[0] = 0xE0 | (c >> 12); data[1] = 0x80 | ((c >> 6) & 0x3F); data[2] = 0x80 | (c & 0x3F); len = 3; }\n"
+	cl.head += "    else { data[0] = 0xF0 | (c >> 18); data[1] = 0x80 | ((c >> 12) & 0x3F); data[2] = 0x80 | ((c >> 6) & 0x3F); data[3] = 0x80 | (c & 0x3F); len = 4; }\n"
+	cl.head += "    data[len] = '\\0';\n"
+	cl.head += fmt.Sprintf("    return (%s){ data, len };\n}\n", stringName)
+
+	cl.helpers = append(cl.helpers, name)
+
+	return name
+}
+
+// Compiles for (var c in text) to a loop decoding the code point at the offset each iteration.
+// The iterated string is held as temporary, freed after the loop, with a mark for the
+// statements of the loop shadowing the mark of the function.
+func compileForIn(cl *compiler, statement *parser.Statement) (string, error) {
+	context := &statement.Context
+
+	iterated, err := compileExpression(cl, statement.Right, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	stringName := importString(cl)
+
+	// Statements of the loop could change the variable viewed
+	if !isFresh(statement.Right) && statement.Right.Type != parser.StringLiteral {
+		iterated = compileTemporary(cl, stringName+"_copy("+iterated+")", parser.ActualType{Id: parser.String})
+	}
+
+	id := cl.temporaries
+	cl.temporaries++
+
+	iteratedName := inferName(fmt.Sprintf("iterated%d", id))
+	offsetName := inferName(fmt.Sprintf("offset%d", id))
+	variableName := statement.Initializer.Identifiers[0].Value
+
+	content := indent(cl) + "{\n"
+	cl.indent++
+
+	content += indent(cl) + stringName + " " + iteratedName + " = " + iterated + ";\n"
+
+	decode := fmt.Sprintf("uint32_t %s = %s_decode(%s, &%s);", variableName, importRunes(cl), iteratedName, offsetName)
+
+	temporariesMarked := cl.temporariesMarked
+	cl.temporariesMarked = false
+
+	block, err := compileBlockWith(cl, statement.RunScope, []string{decode})
+
+	if err != nil {
+		return "", err
+	}
+
+	if cl.temporariesMarked {
+		content += indent(cl) + fmt.Sprintf("int64_t %s = %s;\n", inferName("mark"), inferName("temporaries_len"))
+	}
+
+	// The mark of the function is needed for the temporaries of the loop itself
+	cl.temporariesMarked = temporariesMarked
+
+	content += indent(cl) + fmt.Sprintf("for (int64_t %s = 0; %s < %s.len;) ", offsetName, offsetName, iteratedName) + block + "\n"
+
+	cl.indent--
+
+	return content + indent(cl) + "}", nil
+}
+
+// Literals are views of the C string literal
+func compileStringLiteral(cl *compiler, statement *parser.Statement) string {
+	literal := quoteOfC(statement.Value)
+
+	return fmt.Sprintf("((%s){ %s, sizeof(%s) - 1 })", importString(cl), literal, literal)
+}
+
+// Escapes the bytes of value for a C string literal. Bytes besides printable
+// ASCII are octal escapes, those end after 3 digits unlike hexadecimal ones.
+func quoteOfC(value string) string {
+	quoted := "\""
+
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+
+		switch {
+		case ch == '"' || ch == '\\':
+			quoted += "\\" + string(ch)
+		case ch == '\n':
+			quoted += "\\n"
+		case ch == '\t':
+			quoted += "\\t"
+		case ch == '\r':
+			quoted += "\\r"
+		// Avoid trigraphs like ??=
+		case ch == '?' && i > 0 && value[i-1] == '?':
+			quoted += "\\?"
+		case ch < ' ' || ch > '~':
+			quoted += fmt.Sprintf("\\%03o", ch)
+		default:
+			quoted += string(ch)
+		}
+	}
+
+	return quoted + "\""
+}
+
+func isString(aType parser.ActualType) bool {
+	return aType.Id == parser.String && len(aType.ArraySizes) == 0
+}
+
+func isStringOperation(statement *parser.Statement) bool {
+	return len(statement.ArgTypes) > 0 && isString(statement.ArgTypes[0])
+}
+
+// Compiles concatenation or comparison of strings
+func compileStringOperation(cl *compiler, statement *parser.Statement, context *parser.Scope) (string, error) {
+	left, err := compileExpression(cl, statement.Left, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	right, err := compileExpression(cl, statement.Right, context)
+
+	if err != nil {
+		return "", err
+	}
+
+	name := importString(cl)
+
+	switch statement.Operator {
+	case parser.AdditionOperation:
+		return fmt.Sprintf("%s_concat(%s, %s)", name, left, right), nil
+	case parser.NotEqualsOperation:
+		return fmt.Sprintf("!%s_equals(%s, %s)", name, left, right), nil
+	}
+
+	return fmt.Sprintf("%s_equals(%s, %s)", name, left, right), nil
+}
@@ -2,35 +2,49 @@ package context
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/yonedash/comet/analysis"
+	"github.com/yonedash/comet/diagnostics"
 	"github.com/yonedash/comet/parser"
 )
 
 type StaticError struct {
-	message string
-	trace   analysis.SourceTrace
+	diagnostic diagnostics.Diagnostic
 }
 
 func (e StaticError) Error() string {
-	return e.message
+	return e.diagnostic.Error()
+}
+
+func (e StaticError) Unwrap() error {
+	return e.diagnostic
 }
 
 func fail(statement *parser.Statement, message string) error {
-	// Return error if unknown character is in source
-	trace := statement.Trace
+	return StaticError{diagnostic: diagnostics.New(diagnostics.Error, diagnostics.CodeStatic, statement.Trace, message)}
+}
+
+// Fails for a name declared twice, pointing to the first declaration
+func failRedeclared(trace analysis.SourceTrace, first analysis.SourceTrace, message string) error {
+	diagnostic := diagnostics.New(diagnostics.Error, diagnostics.CodeRedeclared, trace, message)
 
-	row, col := trace.Row, trace.Column
-	msg := fmt.Sprintf("%s @ %d:%d >> %v", message, row, col, statement)
+	if first.Row > 0 {
+		diagnostic = diagnostic.WithSecondary(first, "first declared here")
+	}
 
-	return StaticError{message: msg, trace: trace}
+	return StaticError{diagnostic: diagnostic}
 }
 
 type staticAnalyzer struct {
+	options      analysis.Options
 	statements   []*parser.Statement
 	currentScope parser.Scope
-	hints        []Hint
+	hints        []diagnostics.Diagnostic // Warnings
 	length       int
 	index        int
 }
@@ -65,11 +79,6 @@ func (r staticAnalyzer) isDone() bool {
 	return r.index >= r.length
 }
 
-type Hint struct {
-	Message   string
-	Statement parser.Statement
-}
-
 type insertOrder struct {
 	index     int
 	statement parser.Statement
@@ -85,15 +94,17 @@ func (i insertOrder) insert(parent *parser.Statement) {
 	parent.Children[i.index] = &i.statement
 }
 
-func Grow(statement *parser.Statement) ([]Hint, error) {
-	analyzer, err := analyzeInstance(statement, parser.Scope{})
+// Analyzes the statement and its children, returns the warnings found
+func Grow(statement *parser.Statement, options analysis.Options) ([]diagnostics.Diagnostic, error) {
+	analyzer, err := analyzeInstance(statement, parser.Scope{}, options)
 	return analyzer.hints, err
 }
 
-func analyzeInstance(root *parser.Statement, scope parser.Scope) (staticAnalyzer, error) {
+func analyzeInstance(root *parser.Statement, scope parser.Scope, options analysis.Options) (staticAnalyzer, error) {
 	children := root.Children
 
 	analyzer := staticAnalyzer{
+		options:      options,
 		currentScope: scope,
 		statements:   children,
 		length:       len(children),
@@ -135,6 +146,15 @@ func analyzeStatement(analyzer *staticAnalyzer, statement *parser.Statement) err
 	case parser.FunctionDeclaration:
 		return analyzeFunctionDeclaration(analyzer, statement)
 
+	case parser.StructDeclaration:
+		return analyzeStructDeclaration(analyzer, statement)
+
+	case parser.EnumDeclaration:
+		return analyzeEnumDeclaration(analyzer, statement)
+
+	case parser.MatchStatement:
+		return analyzeMatch(analyzer, statement)
+
 	case parser.VariableDeclaration:
 		return analyzeVariableDeclaration(analyzer, statement)
 
@@ -147,13 +167,32 @@ func analyzeStatement(analyzer *staticAnalyzer, statement *parser.Statement) err
 	case parser.FunctionExpression:
 		return analyzeFunctionExpression(analyzer, statement)
 
+	case parser.ConditionalStatement:
+		return analyzeConditional(analyzer, statement)
+
+	case parser.LoopStatement:
+		return analyzeLoop(analyzer, statement)
+
+	case parser.ReturnStatement:
+		return analyzeReturn(analyzer, statement)
+
+	case parser.BreakStatement:
+		if !analyzer.currentScope.IsInLoop() {
+			return fail(statement, "Cannot break outside of loop")
+		}
+
+	case parser.ContinueStatement:
+		if !analyzer.currentScope.IsInLoop() {
+			return fail(statement, "Cannot continue outside of loop")
+		}
+
 	}
 
 	return nil
 }
 
 func analyzeRoot(analyzer *staticAnalyzer, statement *parser.Statement) error {
-	a, err := analyzeInstance(statement, analyzer.currentScope)
+	a, err := analyzeInstance(statement, analyzer.currentScope, analyzer.options)
 	if err != nil {
 		return err
 	}
@@ -167,7 +206,7 @@ func analyzeScopeDeclaration(analyzer *staticAnalyzer, statement *parser.Stateme
 	newScope := parser.Scope{Parent: &initialScope}
 
 	caller := statement.RunCaller
-	if caller.Type == parser.FunctionDeclaration {
+	if caller != nil && caller.Type == parser.FunctionDeclaration {
 		// Define variables of function in new scope
 		function := initialScope.GetFunction(caller.Value)
 
@@ -175,6 +214,8 @@ func analyzeScopeDeclaration(analyzer *staticAnalyzer, statement *parser.Stateme
 			return fail(statement, fmt.Sprintf("Could not get function %s within scope", caller.Value))
 		}
 
+		newScope.Fn = function
+
 		argCount := len(function.FnArgNames)
 		for i := 0; i < argCount; i++ {
 			argName := function.FnArgNames[i]
@@ -185,13 +226,31 @@ func analyzeScopeDeclaration(analyzer *staticAnalyzer, statement *parser.Stateme
 				VarName:       argName,
 				VarConstant:   true,
 				VarOfFunction: true,
+				VarTrace:      caller.Trace,
+			})
+		}
+	}
+
+	if caller != nil && caller.Type == parser.LoopStatement {
+		newScope.Loop = true
+	}
+
+	// Payload of the matched variant is bound like function arguments
+	if caller != nil && caller.Type == parser.MatchArm {
+		for i, name := range caller.ArgNames {
+			newScope.Vars = append(newScope.Vars, parser.ScopeVar{
+				VarType:       caller.ArgTypes[i],
+				VarName:       name,
+				VarConstant:   true,
+				VarOfFunction: true,
+				VarTrace:      caller.Trace,
 			})
 		}
 	}
 
 	analyzer.currentScope = newScope
 
-	a, err := analyzeInstance(statement, analyzer.currentScope)
+	a, err := analyzeInstance(statement, analyzer.currentScope, analyzer.options)
 	if err != nil {
 		return err
 	}
@@ -200,373 +259,1876 @@ func analyzeScopeDeclaration(analyzer *staticAnalyzer, statement *parser.Stateme
 	analyzer.currentScope = initialScope
 
 	// Set context
-	statement.Context = newScope
+	statement.Context = a.currentScope
 
 	return nil
 }
 
-func analyzeFunctionDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
+func analyzeStructDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
 	name := statement.Value
 
 	if analyzer.currentScope.Parent != nil {
-		return fail(statement, "Cannot declare function outside of root scope")
+		return fail(statement, "Cannot declare struct outside of root scope")
 	}
 
-	if analyzer.currentScope.GetFunction(name) != nil {
-		return fail(statement, fmt.Sprintf("Function %s is already declared", name))
+	if declared := analyzer.currentScope.GetType(name); declared != nil {
+		return failRedeclared(statement.Trace, declared.TypeTrace, fmt.Sprintf("Type %s is already declared", name))
 	}
 
-	newFn := parser.ScopeFn{
-		FnTypes:    statement.Types,
-		FnArgNames: statement.ArgNames,
-		FnArgTypes: statement.ArgTypes,
-		FnName:     name,
-	}
+	for i, fieldName := range statement.ArgNames {
+		for _, otherName := range statement.ArgNames[:i] {
+			if otherName == fieldName {
+				return fail(statement, fmt.Sprintf("Field %s of struct %s is already declared", fieldName, name))
+			}
+		}
 
-	analyzer.currentScope.Fns = append(analyzer.currentScope.Fns, newFn)
+		// Only variables free the elements of slices
+		if statement.ArgTypes[i].Id == parser.Slice {
+			return fail(statement, fmt.Sprintf("Field %s of struct %s cannot be a slice", fieldName, name))
+		}
 
-	// Set context
-	statement.Context = analyzer.currentScope
-	statement.ContextFunction = &newFn
+		// Fields can only be of types declared before, so a struct cannot contain itself
+		err := validateType(analyzer, statement.ArgTypes[i], statement)
 
-	runScope := statement.RunScope
-	runScope.RunCaller = statement
-	err := analyzeStatement(analyzer, runScope)
+		if err != nil {
+			return err
+		}
+	}
 
-	if err != nil {
-		return err
+	newType := parser.ScopeType{
+		TypeName:       name,
+		TypeFieldNames: statement.ArgNames,
+		TypeFieldTypes: statement.ArgTypes,
+		TypeTrace:      statement.Trace,
 	}
 
+	analyzer.currentScope.Types = append(analyzer.currentScope.Types, newType)
+
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextType = &newType
+
 	return nil
 }
 
-func analyzeVariableDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
-	assignCount := len(statement.Expressions)
-
-	for i := 0; i < assignCount; i++ {
-		identifier := statement.Identifiers[i]
-		name := identifier.Value
+func analyzeEnumDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	name := statement.Value
 
-		// Check if variable is defined
-		variable := analyzer.currentScope.GetVariable(name)
-		if variable != nil {
-			return fail(statement, fmt.Sprintf("Variable %s is already declared", name))
-		}
+	if analyzer.currentScope.Parent != nil {
+		return fail(statement, "Cannot declare enum outside of root scope")
+	}
 
-		//
-		// !!! TODO Check if (re-)allocation needed, always true for testing right now
-		//
+	if declared := analyzer.currentScope.GetType(name); declared != nil {
+		return failRedeclared(statement.Trace, declared.TypeTrace, fmt.Sprintf("Type %s is already declared", name))
+	}
 
-		expr := statement.Expressions[i]
-		varType := statement.Types[i]
+	newType := parser.ScopeType{TypeName: name, TypeTrace: statement.Trace}
 
-		inferredType, err := inferType(analyzer, expr, statement)
-		if err != nil {
-			return err
+	for _, variant := range statement.Children {
+		if first := newType.GetVariant(variant.Value); first != -1 {
+			return failRedeclared(variant.Trace, statement.Children[first].Trace, fmt.Sprintf("Variant %s of enum %s is already declared", variant.Value, name))
 		}
 
-		if varType.Id > 0 && varType.Id != inferredType.Id {
-			return fail(statement, fmt.Sprintf("Variable type of %s does not match value", name))
-		}
+		for _, payloadType := range variant.ArgTypes {
+			if len(payloadType.ArraySizes) > 0 || payloadType.Id == parser.Slice {
+				return fail(statement, fmt.Sprintf("Payload of variant %s of enum %s cannot be an array or slice", variant.Value, name))
+			}
 
-		if varType.Id == 0 {
-			varType = inferredType
-			statement.Types[i] = inferredType
-		}
+			err := validateType(analyzer, payloadType, statement)
 
-		// Add variable to scope
-		newVar := parser.ScopeVar{
-			VarName:            name,
-			VarType:            varType,
-			VarConstant:        statement.Constant,
-			VarValueExpression: expr,
-			// VarAllocated:       true, ! no ! compiler will decide, always expect to de-allocate
+			if err != nil {
+				return err
+			}
 		}
 
-		analyzer.currentScope.Vars = append(analyzer.currentScope.Vars, newVar)
-
-		// Set context
-		statement.Context = analyzer.currentScope
-		statement.ContextVariable = &newVar
+		newType.TypeVariantNames = append(newType.TypeVariantNames, variant.Value)
+		newType.TypeVariantTypes = append(newType.TypeVariantTypes, variant.ArgTypes)
 	}
 
+	analyzer.currentScope.Types = append(analyzer.currentScope.Types, newType)
+
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextType = &newType
+
 	return nil
 }
 
-func analyzeVariableAssignment(analyzer *staticAnalyzer, statement *parser.Statement) error {
-	assignCount := len(statement.Expressions)
+// Checks arms of match against the variants of the enum, every variant needs to be handled or else given
+func analyzeMatch(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	valueType, err := inferType(analyzer, statement.Condition, statement)
 
-	for i := 0; i < assignCount; i++ {
-		identifier := statement.Identifiers[i]
-		name := identifier.Value
+	if err != nil {
+		return err
+	}
 
-		// Check if variable is defined
-		variable := analyzer.currentScope.GetVariable(name)
-		if variable == nil {
-			return fail(statement, fmt.Sprintf("Variable %s is not defined", name))
-		}
+	enumType := analyzer.currentScope.GetType(valueType.CustomName)
 
-		// Check if variable is constant
-		if variable.VarConstant {
-			return fail(statement, fmt.Sprintf("Variable %s is immutable", name))
+	if valueType.Id != parser.Custom || enumType == nil || !enumType.IsEnum() {
+		return fail(statement.Condition, "Can only match values of enums")
+	}
+
+	handled := map[string]bool{}
+	hasElse := false
+
+	for i, arm := range statement.Children {
+		if arm.Value == "" {
+			if i != len(statement.Children)-1 {
+				return fail(arm, "Else needs to be the last arm of match")
+			}
+
+			hasElse = true
+		} else {
+			variant := enumType.GetVariant(arm.Value)
+
+			if variant == -1 {
+				return fail(arm, fmt.Sprintf("Enum %s has no variant %s", enumType.TypeName, arm.Value))
+			}
+
+			if handled[arm.Value] {
+				return fail(arm, fmt.Sprintf("Variant %s is already handled", arm.Value))
+			}
+
+			payload := enumType.TypeVariantTypes[variant]
+
+			if len(arm.ArgNames) != len(payload) {
+				return fail(arm, fmt.Sprintf("Variant %s has %d value(s), got %d name(s)", arm.Value, len(payload), len(arm.ArgNames)))
+			}
+
+			handled[arm.Value] = true
+			arm.ArgTypes = payload
 		}
 
-		expr := statement.Expressions[i]
+		// Set context
+		arm.Context = analyzer.currentScope
+		arm.ContextType = enumType
 
-		inferredType, err := inferType(analyzer, expr, statement)
+		runScope := arm.RunScope
+		runScope.RunCaller = arm
+		err := analyzeStatement(analyzer, runScope)
 
 		if err != nil {
 			return err
 		}
+	}
 
-		if inferredType.Id != variable.VarType.Id {
-			return fail(statement, fmt.Sprintf("Value of variable %s has an mismatched type", name))
+	missing := []string{}
+	for _, name := range enumType.TypeVariantNames {
+		if !handled[name] {
+			missing = append(missing, name)
 		}
-
-		// Set context
-		statement.Context = analyzer.currentScope
-		statement.ContextVariable = variable
 	}
 
-	return nil
-}
-
-func analyzeIdentifierExpression(analyzer *staticAnalyzer, statement *parser.Statement) error {
-	// check if last in scope, to free var
+	if len(missing) > 0 && !hasElse {
+		return fail(statement, fmt.Sprintf("Match does not handle variant(s) %s of enum %s", strings.Join(missing, ", "), enumType.TypeName))
+	}
 
-	name := statement.Value
-	variable := analyzer.currentScope.GetVariable(name)
+	if len(missing) == 0 && hasElse {
+		elseArm := statement.Children[len(statement.Children)-1]
+		warning := diagnostics.New(diagnostics.Warning, diagnostics.CodeUnreachable, elseArm.Trace, "Else of match is never reached")
 
-	if variable == nil {
-		return fail(statement, fmt.Sprintf("Undefined identifier %s", name))
+		analyzer.hints = append(analyzer.hints, warning.WithNote(fmt.Sprintf("every variant of enum %s is handled", enumType.TypeName)))
 	}
 
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextType = enumType
+
 	return nil
 }
 
-func analyzeFunctionExpression(analyzer *staticAnalyzer, statement *parser.Statement) error {
-	name := statement.Value
-	function := analyzer.currentScope.GetFunction(name)
-
-	if function == nil {
-		return fail(statement, fmt.Sprintf("Undefined function %s", name))
+// Only variables free strings, fields and elements hold literals which are never freed
+func validateStored(value *parser.Statement, aType parser.ActualType) error {
+	if isString(aType) && value.Type != parser.StringLiteral {
+		return fail(value, "Fields and elements can only hold string literals, only variables free strings")
 	}
 
-	functionArgTypes := function.FnArgTypes
-	argTypeCount := len(functionArgTypes)
-	inputArgs := statement.Expressions
-	argInputCount := len(inputArgs)
+	return nil
+}
 
-	if argInputCount != argTypeCount && (argTypeCount == 0 || !functionArgTypes[argTypeCount-1].Variadic) {
-		return fail(statement, "Invalid argument count")
+// Checks that custom types are declared
+func validateType(analyzer *staticAnalyzer, aType parser.ActualType, statement *parser.Statement) error {
+	if aType.Id == parser.Custom && analyzer.currentScope.GetType(aType.CustomName) == nil {
+		return fail(statement, fmt.Sprintf("Undefined type %s", aType.CustomName))
 	}
 
-	for i := 0; i < argInputCount; i++ {
-		var expectedType parser.ActualType
-
-		if i >= argTypeCount {
-			expectedType = functionArgTypes[argTypeCount-1]
-		} else {
-			expectedType = functionArgTypes[i]
-		}
-
-		expression := inputArgs[i]
-		inferredType, err := inferType(analyzer, expression, statement)
-
-		if err != nil {
-			return err
-		}
-
-		if expectedType.Id != inferredType.Id {
-			return fail(statement, fmt.Sprintf("Invalid type in argument #%d in function call %s (%d != %d)", i, name, expectedType.Id, inferredType.Id))
-		}
+	if aType.Id == parser.Slice {
+		return validateType(analyzer, *aType.ElementType, statement)
 	}
 
 	return nil
 }
 
-func isUsingVariable(statement parser.Statement, variable parser.ScopeVar) bool {
-	switch statement.Type {
+func analyzeConditional(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	conditionType, err := inferType(analyzer, statement.Condition, statement)
 
-	case parser.VariableDeclaration, parser.VariableAssignment:
-		for _, identifier := range statement.Identifiers {
-			if isUsingVariable(*identifier, variable) {
-				return true
-			}
-		}
-		for _, expr := range statement.Expressions {
-			if isUsingVariable(*expr, variable) {
-				return true
-			}
-		}
+	if err != nil {
+		return err
+	}
 
-	case parser.BinaryExpression:
-		leftUsing := isUsingVariable(*statement.Left, variable)
-		rightUsing := isUsingVariable(*statement.Right, variable)
+	if !isBool(conditionType) {
+		return fail(statement.Condition, "Condition of if must be a bool")
+	}
 
-		if leftUsing {
-			return true
-		}
+	// Set context
+	statement.Context = analyzer.currentScope
 
-		if rightUsing {
-			return true
-		}
+	runScope := statement.RunScope
+	runScope.RunCaller = statement
+	err = analyzeStatement(analyzer, runScope)
 
-	case parser.IdentifierExpression:
-		return variable.VarName == statement.Value
+	if err != nil {
+		return err
 	}
 
-	return false
+	if statement.ElseScope == nil {
+		return nil
+	}
+
+	elseScope := statement.ElseScope
+	elseScope.RunCaller = statement
+
+	return analyzeStatement(analyzer, elseScope)
 }
 
-func generateAndCleanUp(analyzer *staticAnalyzer, parent *parser.Statement) error {
-	scope := analyzer.currentScope
+func analyzeLoop(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	// Variables of the initializer live in their own scope around the loop
+	initialScope := analyzer.currentScope
+	analyzer.currentScope = parser.Scope{Parent: &initialScope}
 
-	offset := 1 // De-allocate after index
+	err := analyzeLoopHeader(analyzer, statement)
 
-	children := parent.Children
+	if err == nil {
+		runScope := statement.RunScope
+		runScope.RunCaller = statement
+		err = analyzeStatement(analyzer, runScope)
+	}
 
-	for _, variable := range scope.Vars {
-		cv := variable
+	// Set context
+	statement.Context = analyzer.currentScope
 
-		lastUsageIndex := -1
+	analyzer.currentScope = initialScope
 
-		usageCount := 0
-		firstUsage := parser.Statement{}
+	return err
+}
 
-		for i := 0; i < len(children); i++ {
-			child := children[i]
+func analyzeLoopHeader(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	// Iterated before its variable is declared, so it cannot refer to it
+	if statement.Right != nil {
+		iteratedType, err := inferType(analyzer, statement.Right, statement)
 
-			if isUsingVariable(*child, variable) {
-				lastUsageIndex = i
-				usageCount++
+		if err != nil {
+			return err
+		}
 
-				if usageCount == 1 {
-					firstUsage = *child
-				}
-			}
+		if !isString(iteratedType) {
+			return fail(statement.Right, "Can only iterate over code points of strings")
 		}
+	}
 
-		fmt.Println(variable.VarName, usageCount)
+	if statement.Initializer != nil {
+		err := analyzeStatement(analyzer, statement.Initializer)
 
-		if usageCount <= 1 && !variable.VarOfFunction {
-			return fail(&firstUsage, fmt.Sprintf("Unused variable %s", variable.VarName))
+		if err != nil {
+			return err
 		}
+	}
 
-		// Always append freeing statement, compiler needs to decide whether to act on it or not!
+	if statement.Condition != nil {
+		conditionType, err := inferType(analyzer, statement.Condition, statement)
 
-		stmt := &parser.Statement{
-			Type:            parser.MemoryDeAllocation,
-			Context:         analyzer.currentScope,
-			ContextVariable: &cv,
+		if err != nil {
+			return err
 		}
 
-		index := lastUsageIndex + offset
-		offset++
-
-		if index == len(parent.Children) {
-			parent.Children = append(parent.Children, stmt)
-		} else {
-			parent.Children = append(parent.Children[:index+1], parent.Children[index:]...)
-			parent.Children[index] = stmt
+		if !isBool(conditionType) {
+			return fail(statement.Condition, "Condition of loop must be a bool")
 		}
+	}
 
+	if statement.Step != nil {
+		return analyzeStatement(analyzer, statement.Step)
 	}
 
 	return nil
 }
 
-func inferType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
-	switch expression.Type {
-	case parser.NumberLiteral:
-		value := expression.Value
+func analyzeReturn(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	function := analyzer.currentScope.GetEnclosingFunction()
 
-		floating := strings.Contains(value, ".")
-		// TODO get number type by MAX_SIZE
+	if function == nil {
+		return fail(statement, "Cannot return outside of function")
+	}
 
-		// Check for unsigned ints
-		if value[0] != '-' {
+	types := function.FnTypes
+	values := statement.Expressions
 
-		}
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextFunction = function
 
-		if floating {
-			return parser.ActualType{Id: parser.Float32}, nil
+	if len(types) == 1 && types[0].Id == parser.Void {
+		if len(values) > 0 {
+			return fail(statement, fmt.Sprintf("Function %s does not return any value", function.FnName))
 		}
 
-		return parser.ActualType{Id: parser.Int32}, nil
+		return nil
+	}
 
-	case parser.BooleanLiteral:
-		return parser.ActualType{Id: parser.Bool}, nil
+	if len(values) != len(types) {
+		return fail(statement, fmt.Sprintf("Function %s returns %d value(s), got %d", function.FnName, len(types), len(values)))
+	}
 
-	case parser.StringLiteral:
-		return parser.ActualType{Id: parser.String}, nil
+	for i, value := range values {
+		inferredType, err := inferType(analyzer, value, statement)
 
-	case parser.IdentifierExpression:
-		value := expression.Value
+		if err != nil {
+			return err
+		}
+
+		if !convertImplicitly(value, inferredType, types[i]) {
+			return fail(value, fmt.Sprintf("Return value #%d of function %s has a mismatched type", i, function.FnName))
+		}
+	}
+
+	return nil
+}
+
+func analyzeFunctionDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	name := statement.Value
+
+	if analyzer.currentScope.Parent != nil {
+		return fail(statement, "Cannot declare function outside of root scope")
+	}
+
+	if declared := analyzer.currentScope.GetFunction(name); declared != nil {
+		return failRedeclared(statement.Trace, declared.FnTrace, fmt.Sprintf("Function %s is already declared", name))
+	}
+
+	for _, aType := range append(statement.ArgTypes, statement.Types...) {
+		err := validateType(analyzer, aType, statement)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, aType := range statement.Types {
+		if len(aType.ArraySizes) > 0 {
+			return fail(statement, fmt.Sprintf("Function %s cannot return an array", name))
+		}
+	}
+
+	newFn := parser.ScopeFn{
+		FnTypes:    statement.Types,
+		FnArgNames: statement.ArgNames,
+		FnArgTypes: statement.ArgTypes,
+		FnName:     name,
+		FnNative:   statement.Native,
+		FnTrace:    statement.Trace,
+	}
+
+	analyzer.currentScope.Fns = append(analyzer.currentScope.Fns, newFn)
+
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextFunction = &newFn
+
+	runScope := statement.RunScope
+	runScope.RunCaller = statement
+	err := analyzeStatement(analyzer, runScope)
+
+	if err != nil {
+		return err
+	}
+
+	// C leaves the returned value undefined if the function ends without return
+	returnsValue := len(statement.Types) > 0 && !(len(statement.Types) == 1 && statement.Types[0].Id == parser.Void)
+
+	if returnsValue && !statement.Native && !isTerminating(runScope) {
+		end := runScope.Trace
+		end.Index, end.Row, end.Column = end.End-1, end.EndRow, end.EndColumn-1
+
+		diagnostic := diagnostics.New(diagnostics.Error, diagnostics.CodeStatic, end, fmt.Sprintf("Function %s can end without returning a value", name))
+
+		return StaticError{diagnostic: diagnostic.WithLabel("missing return").WithSecondary(statement.Trace, "function declared here")}
+	}
+
+	return nil
+}
+
+// Checks if every path through the statement returns, so nothing after it runs
+func isTerminating(statement *parser.Statement) bool {
+	switch statement.Type {
+	case parser.ReturnStatement:
+		return true
+	case parser.ScopeDeclaration:
+		// De-allocations inserted after the last statement never run if it returns
+		for i := len(statement.Children) - 1; i >= 0; i-- {
+			if child := statement.Children[i]; child.Type != parser.MemoryDeAllocation {
+				return isTerminating(child)
+			}
+		}
+	case parser.ConditionalStatement:
+		return statement.ElseScope != nil && isTerminating(statement.RunScope) && isTerminating(statement.ElseScope)
+	case parser.MatchStatement:
+		// Arms handle every variant, the analyzer checked it
+		for _, arm := range statement.Children {
+			if !isTerminating(arm.RunScope) {
+				return false
+			}
+		}
+
+		return len(statement.Children) > 0
+	case parser.LoopStatement:
+		// Loops without condition only end by break
+		if statement.Condition != nil || statement.Right != nil {
+			return false
+		}
+
+		for _, exit := range collectExits(statement.RunScope, false, nil) {
+			if exit.Type == parser.BreakStatement {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func analyzeVariableDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	if statement.Destructure {
+		return analyzeDestructuringDeclaration(analyzer, statement)
+	}
+
+	assignCount := len(statement.Expressions)
+
+	// Variables without value start zeroed
+	if assignCount == 0 {
+		for i, identifier := range statement.Identifiers {
+			if statement.Constant {
+				return fail(statement, fmt.Sprintf("Constant %s needs a value", identifier.Value))
+			}
+
+			err := declareVariable(analyzer, statement, i, statement.Types[i], nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i < assignCount; i++ {
+		//
+		// !!! TODO Check if (re-)allocation needed, always true for testing right now
+		//
+
+		expr := statement.Expressions[i]
+
+		inferredType, err := inferType(analyzer, expr, statement)
+		if err != nil {
+			return err
+		}
+
+		err = declareVariable(analyzer, statement, i, inferredType, expr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Declares each identifier with one of the values returned by the function expression
+func analyzeDestructuringDeclaration(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	expr := statement.Expressions[0]
+
+	err := analyzeFunctionExpression(analyzer, expr)
+	if err != nil {
+		return err
+	}
+
+	function := expr.ContextFunction
+	identifierCount := len(statement.Identifiers)
+
+	if len(function.FnTypes) != identifierCount || function.FnTypes[0].Id == parser.Void {
+		return fail(statement, fmt.Sprintf("Function %s returns %d value(s), cannot declare %d variables", function.FnName, len(function.FnTypes), identifierCount))
+	}
+
+	for i := 0; i < identifierCount; i++ {
+		err := declareVariable(analyzer, statement, i, function.FnTypes[i], expr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Adds identifier i of the declaration with the type of its value to the scope
+func declareVariable(analyzer *staticAnalyzer, statement *parser.Statement, i int, inferredType parser.ActualType, expr *parser.Statement) error {
+	identifier := statement.Identifiers[i]
+	name := identifier.Value
+
+	// Check if variable is defined
+	variable := analyzer.currentScope.GetVariable(name)
+	if variable != nil {
+		return failRedeclared(identifier.Trace, variable.VarTrace, fmt.Sprintf("Variable %s is already declared", name))
+	}
+
+	varType := statement.Types[i]
+
+	err := validateType(analyzer, varType, statement)
+
+	if err != nil {
+		return err
+	}
+
+	// Values of a shared function expression are converted by C when unpacked
+	value := expr
+	if statement.Destructure {
+		value = nil
+	}
+
+	if varType.Id > 0 && !convertImplicitly(value, inferredType, varType) {
+		return fail(expr, fmt.Sprintf("Variable type of %s does not match value", name))
+	}
+
+	if varType.Id == 0 {
+		varType = inferredType
+		statement.Types[i] = inferredType
+	}
+
+	// C cannot copy arrays
+	if len(varType.ArraySizes) > 0 && expr != nil && expr.Type != parser.ArrayLiteral {
+		return fail(expr, fmt.Sprintf("Array %s can only be initialized with an array literal", name))
+	}
+
+	// Add variable to scope
+	newVar := parser.ScopeVar{
+		VarName:            name,
+		VarType:            varType,
+		VarConstant:        statement.Constant,
+		VarValueExpression: expr,
+		VarTrace:           identifier.Trace,
+		// VarAllocated:       true, ! no ! compiler will decide, always expect to de-allocate
+	}
+
+	analyzer.currentScope.Vars = append(analyzer.currentScope.Vars, newVar)
+
+	// Set context
+	statement.Context = analyzer.currentScope
+	statement.ContextVariable = &newVar
+
+	return nil
+}
+
+func analyzeVariableAssignment(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	assignCount := len(statement.Expressions)
+	statement.Types = make([]parser.ActualType, assignCount)
+
+	for i := 0; i < assignCount; i++ {
+		identifier := statement.Identifiers[i]
+
+		// Fields and elements are assigned through the variable holding the struct or array
+		target := identifier
+		for target.Type == parser.FieldExpression || target.Type == parser.IndexExpression {
+			target = target.Left
+		}
+
+		if target.Type != parser.IdentifierExpression {
+			return fail(identifier, "Can only assign to variables, their fields and elements")
+		}
+
+		name := target.Value
+
+		// Check if variable is defined
+		variable := analyzer.currentScope.GetVariable(name)
+		if variable == nil {
+			return fail(target, fmt.Sprintf("Variable %s is not defined", name))
+		}
+
+		// Check if variable is constant
+		if variable.VarConstant {
+			return fail(identifier, fmt.Sprintf("Variable %s is immutable", name))
+		}
+
+		targetType, err := inferType(analyzer, identifier, statement)
+
+		if err != nil {
+			return err
+		}
+
+		statement.Types[i] = targetType
+
+		if len(targetType.ArraySizes) > 0 {
+			return fail(identifier, fmt.Sprintf("Cannot assign array %s as a whole, assign its elements instead", name))
+		}
+
+		if identifier.Type == parser.IndexExpression && isString(identifier.ArgTypes[0]) {
+			return fail(identifier, fmt.Sprintf("Cannot assign to bytes of string %s, strings are immutable", name))
+		}
+
+		expr := statement.Expressions[i]
+
+		inferredType, err := inferType(analyzer, expr, statement)
+
+		if err != nil {
+			return err
+		}
+
+		if !convertImplicitly(expr, inferredType, targetType) {
+			return fail(expr, fmt.Sprintf("Value of variable %s has an mismatched type", name))
+		}
+
+		if identifier.Type != parser.IdentifierExpression {
+			if statement.Compound && isString(targetType) {
+				return fail(identifier, "Cannot concatenate to strings of fields and elements, only variables free strings")
+			}
+
+			if err := validateStored(expr, targetType); err != nil {
+				return err
+			}
+		}
+
+		if statement.Compound && !(isString(targetType) && statement.Operator == parser.AdditionOperation) {
+			if !isNumeric(targetType) {
+				return fail(identifier, fmt.Sprintf("Variable %s is not a number", name))
+			}
+
+			if statement.Operator == parser.ModulusOperation && !isInteger(targetType) {
+				return fail(identifier, fmt.Sprintf("Variable %s is not an integer", name))
+			}
+		}
+
+		// Set context
+		statement.Context = analyzer.currentScope
+		statement.ContextVariable = variable
+	}
+
+	return nil
+}
+
+func analyzeIdentifierExpression(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	// check if last in scope, to free var
+
+	name := statement.Value
+	variable := analyzer.currentScope.GetVariable(name)
+
+	if variable == nil {
+		return fail(statement, fmt.Sprintf("Undefined identifier %s", name))
+	}
+
+	return nil
+}
+
+func analyzeFunctionExpression(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	name := statement.Value
+	function := analyzer.currentScope.GetFunction(name)
+
+	if function == nil && (name == "len" || name == "append") {
+		return analyzeBuiltinExpression(analyzer, statement)
+	}
+
+	if function == nil {
+		return fail(statement, fmt.Sprintf("Undefined function %s", name))
+	}
+
+	// Set context
+	statement.ContextFunction = function
+
+	functionArgTypes := function.FnArgTypes
+	argTypeCount := len(functionArgTypes)
+	inputArgs := statement.Expressions
+	argInputCount := len(inputArgs)
+
+	if argInputCount != argTypeCount && (argTypeCount == 0 || !functionArgTypes[argTypeCount-1].Variadic) {
+		return fail(statement, "Invalid argument count")
+	}
+
+	// Types of the passed values
+	statement.ArgTypes = []parser.ActualType{}
+
+	for i := 0; i < argInputCount; i++ {
+		var expectedType parser.ActualType
+
+		if i >= argTypeCount {
+			expectedType = functionArgTypes[argTypeCount-1]
+		} else {
+			expectedType = functionArgTypes[i]
+		}
+
+		expression := inputArgs[i]
+		inferredType, err := inferType(analyzer, expression, statement)
+
+		if err != nil {
+			return err
+		}
+
+		// Additional arguments of ..? are not validated
+		if i >= argTypeCount && expectedType.SkipValidateVariadicType {
+			statement.ArgTypes = append(statement.ArgTypes, inferredType)
+			continue
+		}
+
+		if !convertImplicitly(expression, inferredType, expectedType) {
+			return fail(expression, fmt.Sprintf("Invalid type in argument #%d in function call %s (%s != %s)", i, name, expectedType, inferredType))
+		}
+
+		statement.ArgTypes = append(statement.ArgTypes, expectedType)
+	}
+
+	return nil
+}
+
+// Validates len(value) and append(slice, element), functions declared with the same name are preferred
+func analyzeBuiltinExpression(analyzer *staticAnalyzer, statement *parser.Statement) error {
+	name := statement.Value
+	args := statement.Expressions
+
+	if name == "len" {
+		if len(args) != 1 {
+			return fail(statement, "len needs exactly one argument")
+		}
+
+		valueType, err := inferType(analyzer, args[0], statement)
+
+		if err != nil {
+			return err
+		}
+
+		if len(valueType.ArraySizes) == 0 && valueType.Id != parser.Slice && !isString(valueType) {
+			return fail(args[0], "len needs an array, slice or string")
+		}
+
+		// Set context
+		statement.ContextFunction = &parser.ScopeFn{FnName: name, FnTypes: []parser.ActualType{{Id: parser.Int64}}, FnArgTypes: []parser.ActualType{valueType}, FnBuiltin: true}
+
+		return nil
+	}
+
+	if len(args) != 2 {
+		return fail(statement, "append needs a slice and an element")
+	}
+
+	// Grows the slice in place, so it needs to be owned by a mutable variable
+	target := args[0]
+	variable := analyzer.currentScope.GetVariable(target.Value)
+
+	if target.Type != parser.IdentifierExpression || variable == nil || variable.VarType.Id != parser.Slice {
+		return fail(target, "append needs a slice variable to append to")
+	}
+
+	if variable.VarConstant {
+		return fail(target, fmt.Sprintf("Variable %s is immutable", variable.VarName))
+	}
+
+	element := *variable.VarType.ElementType
+	valueType, err := inferType(analyzer, args[1], statement)
+
+	if err != nil {
+		return err
+	}
+
+	if !convertImplicitly(args[1], valueType, element) {
+		return fail(args[1], fmt.Sprintf("Cannot append value of mismatched type to %s", variable.VarName))
+	}
+
+	if err := validateStored(args[1], element); err != nil {
+		return err
+	}
+
+	// Set context
+	statement.ContextFunction = &parser.ScopeFn{FnName: name, FnTypes: []parser.ActualType{{Id: parser.Void}}, FnArgTypes: []parser.ActualType{variable.VarType, element}, FnBuiltin: true}
+
+	return nil
+}
+
+func deAllocationOf(analyzer *staticAnalyzer, variable *parser.ScopeVar) parser.Statement {
+	return parser.Statement{
+		Type:            parser.MemoryDeAllocation,
+		Context:         analyzer.currentScope,
+		ContextVariable: variable,
+	}
+}
+
+func isExit(statement parser.Statement) bool {
+	return statement.Type == parser.ReturnStatement || statement.Type == parser.BreakStatement || statement.Type == parser.ContinueStatement
+}
+
+// Collects the exits of statement that leave the scope around it: returns,
+// breaks and continues outside of loops nested in statement
+func collectExits(statement *parser.Statement, inLoop bool, exits []*parser.Statement) []*parser.Statement {
+	switch statement.Type {
+	case parser.FunctionDeclaration:
+		return exits
+	case parser.ReturnStatement:
+		return append(exits, statement)
+	case parser.BreakStatement, parser.ContinueStatement:
+		if inLoop {
+			return exits
+		}
+
+		return append(exits, statement)
+	case parser.LoopStatement:
+		inLoop = true
+	}
+
+	for _, child := range statement.Children {
+		exits = collectExits(child, inLoop, exits)
+	}
+
+	for _, scope := range []*parser.Statement{statement.RunScope, statement.ElseScope} {
+		if scope != nil {
+			exits = collectExits(scope, inLoop, exits)
+		}
+	}
+
+	return exits
+}
+
+// Index of the child declaring the variable, -1 if it is declared outside of them
+func getDeclarationIndex(children []*parser.Statement, variable parser.ScopeVar) int {
+	for i, child := range children {
+		if child.Type != parser.VariableDeclaration {
+			continue
+		}
+
+		for _, identifier := range child.Identifiers {
+			if identifier.Value == variable.VarName {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// Checks if the return hands the variable over to the caller instead of freeing it
+func isReturningVariable(exit parser.Statement, variable parser.ScopeVar) bool {
+	if exit.Type != parser.ReturnStatement {
+		return false
+	}
+
+	for _, value := range exit.Expressions {
+		if value.Type == parser.IdentifierExpression && value.Value == variable.VarName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isUsingVariable(statement parser.Statement, variable parser.ScopeVar) bool {
+	switch statement.Type {
+
+	case parser.VariableDeclaration, parser.VariableAssignment:
+		for _, identifier := range statement.Identifiers {
+			if isUsingVariable(*identifier, variable) {
+				return true
+			}
+		}
+		for _, expr := range statement.Expressions {
+			if isUsingVariable(*expr, variable) {
+				return true
+			}
+		}
+
+	case parser.FunctionExpression, parser.ReturnStatement:
+		for _, expr := range statement.Expressions {
+			if isUsingVariable(*expr, variable) {
+				return true
+			}
+		}
+
+	case parser.ScopeDeclaration:
+		for _, child := range statement.Children {
+			if isUsingVariable(*child, variable) {
+				return true
+			}
+		}
+
+	case parser.LoopStatement:
+		for _, part := range []*parser.Statement{statement.Initializer, statement.Right, statement.Condition, statement.Step, statement.RunScope} {
+			if part != nil && isUsingVariable(*part, variable) {
+				return true
+			}
+		}
+
+	case parser.ConditionalStatement:
+		if isUsingVariable(*statement.Condition, variable) || isUsingVariable(*statement.RunScope, variable) {
+			return true
+		}
+
+		if statement.ElseScope != nil {
+			return isUsingVariable(*statement.ElseScope, variable)
+		}
+
+	case parser.UnaryExpression, parser.ConversionExpression:
+		return isUsingVariable(*statement.Right, variable)
+
+	case parser.FieldExpression:
+		for _, expr := range statement.Expressions {
+			if isUsingVariable(*expr, variable) {
+				return true
+			}
+		}
+
+		return isUsingVariable(*statement.Left, variable)
+
+	case parser.MatchStatement:
+		if isUsingVariable(*statement.Condition, variable) {
+			return true
+		}
+
+		for _, arm := range statement.Children {
+			if isUsingVariable(*arm.RunScope, variable) {
+				return true
+			}
+		}
+
+	case parser.IndexExpression:
+		return isUsingVariable(*statement.Left, variable) || isUsingVariable(*statement.Right, variable)
+
+	case parser.SliceExpression:
+		for _, bound := range statement.Expressions {
+			if bound != nil && isUsingVariable(*bound, variable) {
+				return true
+			}
+		}
+
+		return isUsingVariable(*statement.Left, variable)
+
+	case parser.StructLiteral, parser.ArrayLiteral:
+		for _, expr := range statement.Expressions {
+			if isUsingVariable(*expr, variable) {
+				return true
+			}
+		}
+
+	case parser.BinaryExpression:
+		leftUsing := isUsingVariable(*statement.Left, variable)
+		rightUsing := isUsingVariable(*statement.Right, variable)
+
+		if leftUsing {
+			return true
+		}
+
+		if rightUsing {
+			return true
+		}
+
+	case parser.IdentifierExpression:
+		return variable.VarName == statement.Value
+	}
+
+	return false
+}
+
+func generateAndCleanUp(analyzer *staticAnalyzer, parent *parser.Statement) error {
+	scope := analyzer.currentScope
+
+	// Insertions shift the children, so look at them as they were before
+	children := append([]*parser.Statement{}, parent.Children...)
+	insertions := []insertOrder{}
+
+	exits := make([][]*parser.Statement, len(children))
+	for i, child := range children {
+		exits[i] = collectExits(child, false, nil)
+	}
+
+	for _, variable := range scope.Vars {
+		cv := variable
+
+		lastUsageIndex := -1
+
+		usageCount := 0
+		firstUsage := parser.Statement{}
+
+		for i := 0; i < len(children); i++ {
+			child := children[i]
+
+			if isUsingVariable(*child, variable) {
+				lastUsageIndex = i
+				usageCount++
+
+				if usageCount == 1 {
+					firstUsage = *child
+				}
+			}
+		}
+
+		analyzer.options.Debugf("context: variable %s used %d times", variable.VarName, usageCount)
+
+		// Variables own the memory of their slices and strings, arguments only borrow it
+		cv.ALLOCATED = (cv.VarType.Id == parser.Slice || isString(cv.VarType)) && !cv.VarOfFunction
+
+		if usageCount <= 1 && !variable.VarOfFunction {
+			diagnostic := diagnostics.New(diagnostics.Error, diagnostics.CodeUnusedVariable, variable.VarTrace, fmt.Sprintf("Unused variable %s", variable.VarName))
+
+			if variable.VarTrace.Row <= 0 {
+				diagnostic.Primary.Trace = firstUsage.Trace
+			}
+
+			return StaticError{diagnostic: diagnostic}
+		}
+
+		// Exits between declaration and last usage leave the variable while it is alive
+		if cv.ALLOCATED {
+			for i := getDeclarationIndex(children, cv) + 1; i <= lastUsageIndex; i++ {
+				for _, exit := range exits[i] {
+					if !isReturningVariable(*exit, cv) {
+						deAllocation := deAllocationOf(analyzer, &cv)
+						exit.Cleanup = append(exit.Cleanup, &deAllocation)
+					}
+				}
+			}
+		}
+
+		// Unused arguments and payloads have nothing to free after
+		if lastUsageIndex < 0 {
+			continue
+		}
+
+		// Nothing runs after the last usage if it exits, the exit frees the variable itself
+		if last := children[lastUsageIndex]; isExit(*last) {
+			continue
+		}
+
+		// Always append freeing statement, compiler needs to decide whether to act on it or not!
+
+		insertions = append(insertions, insertOrder{
+			index:     lastUsageIndex + 1, // De-allocate after index
+			statement: deAllocationOf(analyzer, &cv),
+		})
+	}
+
+	// Insert from the back to keep the indices of the remaining insertions valid
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].index > insertions[j].index
+	})
+
+	for _, insertion := range insertions {
+		insertion.insert(parent)
+	}
+
+	return nil
+}
+
+func inferType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	switch expression.Type {
+	case parser.NumberLiteral:
+		return inferNumberType(expression, statement)
+
+	case parser.BooleanLiteral:
+		return parser.ActualType{Id: parser.Bool}, nil
+
+	case parser.StringLiteral:
+		return parser.ActualType{Id: parser.String}, nil
+
+	case parser.CharacterLiteral:
+		return parser.ActualType{Id: parser.Rune}, nil
+
+	case parser.IdentifierExpression:
+		value := expression.Value
+
+		scopeVariable := analyzer.currentScope.GetVariable(value)
+
+		if scopeVariable == nil {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Undefined identifier %s", value))
+		}
+
+		return scopeVariable.VarType, nil
+
+	case parser.BinaryExpression:
+		return inferBinaryType(analyzer, expression)
+
+	case parser.UnaryExpression:
+		return inferUnaryType(analyzer, expression)
+
+	case parser.ConversionExpression:
+		return inferConversionType(analyzer, expression, statement)
+
+	case parser.FieldExpression:
+		return inferFieldType(analyzer, expression, statement)
+
+	case parser.StructLiteral:
+		return inferStructLiteralType(analyzer, expression, statement)
+
+	case parser.IndexExpression:
+		return inferIndexType(analyzer, expression, statement)
+
+	case parser.SliceExpression:
+		return inferSliceExpressionType(analyzer, expression, statement)
+
+	case parser.ArrayLiteral:
+		return inferArrayLiteralType(analyzer, expression, statement)
+
+	case parser.FunctionExpression:
+		value := expression.Value
+
+		err := analyzeFunctionExpression(analyzer, expression)
+
+		if err != nil {
+			return parser.ActualType{}, err
+		}
+
+		function := expression.ContextFunction
+		types := function.FnTypes
+		typeCount := len(types)
+
+		if typeCount == 0 || (typeCount == 1 && types[0].Id == parser.Void) {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Function %s does not return any value", value))
+		}
+
+		if typeCount > 1 {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Function %s returns multiple values, can only accept one", value))
+		}
+
+		return types[0], nil
+	}
+
+	return parser.ActualType{}, fail(expression, "Undefined type")
+}
+
+func inferBinaryType(analyzer *staticAnalyzer, statement *parser.Statement) (parser.ActualType, error) {
+	if statement.Left == nil {
+		return parser.ActualType{}, fail(statement, "Left side could not be dereferenced")
+	}
+
+	leftType, err := inferType(analyzer, statement.Left, statement)
+
+	if err != nil {
+		return parser.ActualType{}, err
+	}
+
+	if statement.Right == nil {
+		return parser.ActualType{}, fail(statement, "Left side could not be dereferenced")
+	}
+
+	rightType, err := inferType(analyzer, statement.Right, statement)
+
+	if err != nil {
+		return parser.ActualType{}, err
+	}
+
+	switch statement.Operator {
+	case parser.ShiftLeftOperation, parser.ShiftRightOperation:
+		// Shift count does not change the type of the shifted value
+		if !isInteger(leftType) || !isInteger(rightType) {
+			return parser.ActualType{}, fail(statement, "Modulus and bitwise operators need integer operands")
+		}
+
+		return leftType, nil
+
+	case parser.AndOperation, parser.OrOperation:
+		// Operands are not numbers, checked below
+	default:
+		leftType, rightType, err = combineTypes(statement, leftType, rightType)
+
+		if err != nil {
+			return parser.ActualType{}, err
+		}
+	}
+
+	if !leftType.Equals(rightType) {
+		return parser.ActualType{}, fail(statement, fmt.Sprintf("Cannot combine types %s and %s", leftType, rightType))
+	}
+
+	// Set context
+	statement.ArgTypes = []parser.ActualType{leftType}
+
+	switch statement.Operator {
+	case parser.EqualsOperation, parser.NotEqualsOperation:
+		if leftType.Id == parser.Custom {
+			return parser.ActualType{}, fail(statement, "Cannot compare structs")
+		}
+
+		if len(leftType.ArraySizes) > 0 || leftType.Id == parser.Slice {
+			return parser.ActualType{}, fail(statement, "Cannot compare arrays or slices")
+		}
+
+		return parser.ActualType{Id: parser.Bool}, nil
+
+	case parser.SmallerOperation, parser.SmallerEqualsOperation, parser.BiggerOperation, parser.BiggerEqualsOperation:
+		if !isNumeric(leftType) && !isRune(leftType) {
+			return parser.ActualType{}, fail(statement, "Can only compare order of numbers and runes")
+		}
+
+		return parser.ActualType{Id: parser.Bool}, nil
+
+	case parser.AndOperation, parser.OrOperation:
+		if !isBool(leftType) {
+			return parser.ActualType{}, fail(statement, "Logical operators need bool operands")
+		}
+
+		return parser.ActualType{Id: parser.Bool}, nil
+
+	case parser.AdditionOperation:
+		// Strings are concatenated
+		if !isNumeric(leftType) && !isString(leftType) {
+			return parser.ActualType{}, fail(statement, "Addition needs number or string operands")
+		}
+
+	case parser.SubtractionOperation, parser.MultiplicationOperation, parser.DivisionOperation:
+		if !isNumeric(leftType) {
+			return parser.ActualType{}, fail(statement, "Arithmetic operators need number operands")
+		}
+
+	case parser.ModulusOperation, parser.BitwiseAndOperation, parser.BitwiseOrOperation, parser.BitwiseXorOperation:
+		if !isInteger(leftType) {
+			return parser.ActualType{}, fail(statement, "Modulus and bitwise operators need integer operands")
+		}
+	}
+
+	combinedType := leftType
+
+	return combinedType, nil
+}
+
+func inferUnaryType(analyzer *staticAnalyzer, statement *parser.Statement) (parser.ActualType, error) {
+	operandType, err := inferType(analyzer, statement.Right, statement)
+
+	if err != nil {
+		return parser.ActualType{}, err
+	}
+
+	switch statement.Unary {
+	case parser.NotOperation:
+		if !isBool(operandType) {
+			return parser.ActualType{}, fail(statement, "Operator ! needs a bool operand")
+		}
+
+	case parser.NegateOperation:
+		if !isNumeric(operandType) || isUnsigned(operandType) {
+			return parser.ActualType{}, fail(statement, "Operator - needs a signed number operand")
+		}
+
+	case parser.BitwiseNotOperation:
+		if !isInteger(operandType) {
+			return parser.ActualType{}, fail(statement, "Operator ~ needs an integer operand")
+		}
+	}
+
+	return operandType, nil
+}
+
+// Converts the operands of a binary expression to the smallest number type both widen to
+func combineTypes(statement *parser.Statement, leftType parser.ActualType, rightType parser.ActualType) (parser.ActualType, parser.ActualType, error) {
+	if !isNumeric(leftType) || !isNumeric(rightType) || leftType.Id == rightType.Id {
+		return leftType, rightType, nil
+	}
+
+	// Number literals take the type of the other operand if their value fits
+	if isUntypedNumberFitting(statement.Right, leftType) {
+		return leftType, leftType, nil
+	}
+
+	if isUntypedNumberFitting(statement.Left, rightType) {
+		return rightType, rightType, nil
+	}
+
+	common := parser.ActualType{Id: parser.GetCommonTypeId(leftType, rightType)}
+
+	if common.Id == parser.Void {
+		return parser.ActualType{}, parser.ActualType{}, fail(statement, fmt.Sprintf("Cannot combine types %s and %s without losing precision", leftType, rightType))
+	}
+
+	convertImplicitly(statement.Left, leftType, common)
+	convertImplicitly(statement.Right, rightType, common)
+
+	return common, common, nil
+}
+
+// Checks if a value of type from can be used as type to. Numbers are widened
+// by wrapping the expression in a conversion, narrowing them is not allowed.
+// A nil expression is only checked.
+func convertImplicitly(expression *parser.Statement, from parser.ActualType, to parser.ActualType) bool {
+	if from.Equals(to) {
+		return true
+	}
+
+	if isUntypedNumberFitting(expression, to) {
+		return true
+	}
+
+	// Values of array literals are converted one by one
+	if expression != nil && expression.Type == parser.ArrayLiteral && len(from.ArraySizes) > 0 && len(from.ArraySizes) == len(to.ArraySizes) {
+		if from.ArraySizes[0] != to.ArraySizes[0] {
+			return false
+		}
+
+		fromElement, toElement := elementType(from), elementType(to)
+
+		for _, value := range expression.Expressions {
+			if !convertImplicitly(value, fromElement, toElement) {
+				return false
+			}
+		}
+
+		expression.Types = []parser.ActualType{to}
+
+		return true
+	}
+
+	// Arrays are used as slice of their elements
+	if to.Id == parser.Slice && len(from.ArraySizes) == 1 {
+		array := *to.ElementType
+		array.ArraySizes = from.ArraySizes
+
+		if !convertImplicitly(expression, from, array) {
+			return false
+		}
+
+		wrapInConversion(expression, array, to)
 
-		scopeVariable := analyzer.currentScope.GetVariable(value)
+		return true
+	}
 
-		if scopeVariable == nil {
-			return parser.ActualType{}, fail(statement, fmt.Sprintf("Undefined identifier %s", value))
+	if !isNumeric(from) || !isNumeric(to) || !parser.IsWidening(from.Id, to.Id) {
+		return false
+	}
+
+	wrapInConversion(expression, from, to)
+
+	return true
+}
+
+func wrapInConversion(expression *parser.Statement, from parser.ActualType, to parser.ActualType) {
+	if expression == nil {
+		return
+	}
+
+	operand := *expression
+	*expression = parser.Statement{
+		Type:     parser.ConversionExpression,
+		Right:    &operand,
+		Types:    []parser.ActualType{to},
+		ArgTypes: []parser.ActualType{from},
+		Trace:    operand.Trace,
+	}
+}
+
+// Infers type of the field of the struct on the left: a.b
+func inferFieldType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	object := expression.Left
+
+	// Variant of enum: Enum.Variant(payload)
+	if object.Type == parser.IdentifierExpression && analyzer.currentScope.GetVariable(object.Value) == nil {
+		if enumType := analyzer.currentScope.GetType(object.Value); enumType != nil && enumType.IsEnum() {
+			return inferVariantType(analyzer, expression, statement, enumType)
 		}
+	}
 
-		return scopeVariable.VarType, nil
+	if expression.Expressions != nil {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Cannot call field %s", expression.Value))
+	}
 
-	case parser.BinaryExpression:
-		return inferBinaryType(analyzer, expression)
+	objectType, err := inferType(analyzer, expression.Left, statement)
 
-	case parser.FunctionExpression:
-		value := expression.Value
+	if err != nil {
+		return parser.ActualType{}, err
+	}
 
-		function := analyzer.currentScope.GetFunction(value)
+	scopeType := analyzer.currentScope.GetType(objectType.CustomName)
 
-		if function == nil {
-			return parser.ActualType{}, fail(statement, fmt.Sprintf("Undefined function %s", value))
+	if objectType.Id != parser.Custom || scopeType == nil || scopeType.IsEnum() {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Cannot access field %s of a value that is not a struct", expression.Value))
+	}
+
+	fieldType, found := scopeType.GetField(expression.Value)
+
+	if !found {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Struct %s has no field %s", scopeType.TypeName, expression.Value))
+	}
+
+	// Set context
+	expression.ContextType = scopeType
+
+	return fieldType, nil
+}
+
+// Validates payload of the enum variant
+func inferVariantType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement, enumType *parser.ScopeType) (parser.ActualType, error) {
+	variant := enumType.GetVariant(expression.Value)
+
+	if variant == -1 {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Enum %s has no variant %s", enumType.TypeName, expression.Value))
+	}
+
+	payload := enumType.TypeVariantTypes[variant]
+
+	if len(expression.Expressions) != len(payload) {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Variant %s has %d value(s), got %d", expression.Value, len(payload), len(expression.Expressions)))
+	}
+
+	for i, value := range expression.Expressions {
+		valueType, err := inferType(analyzer, value, statement)
+
+		if err != nil {
+			return parser.ActualType{}, err
 		}
 
-		types := function.FnTypes
-		typeCount := len(types)
+		if !convertImplicitly(value, valueType, payload[i]) {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Value #%d of variant %s has a mismatched type", i, expression.Value))
+		}
 
-		if typeCount == 0 {
-			return parser.ActualType{}, fail(statement, fmt.Sprintf("Function %s does not return any value", value))
+		if err := validateStored(value, payload[i]); err != nil {
+			return parser.ActualType{}, err
 		}
+	}
 
-		if typeCount > 1 {
-			return parser.ActualType{}, fail(statement, fmt.Sprintf("Function %s returns multiple values, can only accept one", value))
+	// Set context
+	expression.ContextType = enumType
+
+	return parser.ActualType{Id: parser.Custom, CustomName: enumType.TypeName}, nil
+}
+
+// Validates fields of struct literal, fields not given are zero
+func inferStructLiteralType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	name := expression.Value
+	scopeType := analyzer.currentScope.GetType(name)
+
+	if scopeType == nil {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Undefined type %s", name))
+	}
+
+	for i, fieldName := range expression.ArgNames {
+		for _, otherName := range expression.ArgNames[:i] {
+			if otherName == fieldName {
+				return parser.ActualType{}, fail(expression, fmt.Sprintf("Field %s is given twice in literal of struct %s", fieldName, name))
+			}
 		}
 
-		return types[0], nil
+		fieldType, found := scopeType.GetField(fieldName)
+
+		if !found {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Struct %s has no field %s", name, fieldName))
+		}
+
+		value := expression.Expressions[i]
+		valueType, err := inferType(analyzer, value, statement)
+
+		if err != nil {
+			return parser.ActualType{}, err
+		}
+
+		if !convertImplicitly(value, valueType, fieldType) {
+			return parser.ActualType{}, fail(value, fmt.Sprintf("Value of field %s of struct %s has a mismatched type", fieldName, name))
+		}
+
+		if len(fieldType.ArraySizes) > 0 && value.Type != parser.ArrayLiteral {
+			return parser.ActualType{}, fail(value, fmt.Sprintf("Array field %s of struct %s can only be initialized with an array literal", fieldName, name))
+		}
+
+		if err := validateStored(value, fieldType); err != nil {
+			return parser.ActualType{}, err
+		}
 	}
 
-	return parser.ActualType{}, fail(statement, "Undefined type")
+	// Set context
+	expression.ContextType = scopeType
+
+	return parser.ActualType{Id: parser.Custom, CustomName: name}, nil
 }
 
-func inferBinaryType(analyzer *staticAnalyzer, statement *parser.Statement) (parser.ActualType, error) {
-	if statement.Left == nil {
-		return parser.ActualType{}, fail(statement, fmt.Sprintf("Left side could not be dereferenced %v", statement))
+// Infers type of the element of the array on the left: a[i]
+func inferIndexType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	arrayType, err := inferType(analyzer, expression.Left, statement)
+
+	if err != nil {
+		return parser.ActualType{}, err
 	}
 
-	leftType, err := inferType(analyzer, statement.Left, statement)
+	if len(arrayType.ArraySizes) == 0 && arrayType.Id != parser.Slice && !isString(arrayType) {
+		return parser.ActualType{}, fail(expression.Left, "Cannot index a value that is not an array, slice or string")
+	}
+
+	indexType, err := inferType(analyzer, expression.Right, statement)
 
 	if err != nil {
 		return parser.ActualType{}, err
 	}
 
-	if statement.Right == nil {
-		return parser.ActualType{}, fail(statement, fmt.Sprintf("Left side could not be dereferenced %v", statement))
+	if !isInteger(indexType) {
+		return parser.ActualType{}, fail(expression.Right, "Index needs to be an integer")
 	}
 
-	rightType, err := inferType(analyzer, statement.Right, statement)
+	// Constant indices are checked against the size, slices are checked at runtime
+	index, isConstant := constantInteger(expression.Right)
+
+	if isConstant && index.Sign() < 0 {
+		return parser.ActualType{}, fail(expression.Right, fmt.Sprintf("Index %s is negative", index.String()))
+	}
+
+	if isConstant && len(arrayType.ArraySizes) > 0 && index.Cmp(big.NewInt(int64(arrayType.ArraySizes[0]))) >= 0 {
+		return parser.ActualType{}, fail(expression.Right, fmt.Sprintf("Index %s is out of bounds of array with size %d", index.String(), arrayType.ArraySizes[0]))
+	}
+
+	element := elementType(arrayType)
+
+	// Set context
+	expression.Types = []parser.ActualType{element}
+	expression.ArgTypes = []parser.ActualType{arrayType}
+
+	return element, nil
+}
+
+// Infers type of slice of the array or slice on the left: a[start:end]
+func inferSliceExpressionType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	slicedType, err := inferType(analyzer, expression.Left, statement)
 
 	if err != nil {
 		return parser.ActualType{}, err
 	}
 
-	if leftType.Id != rightType.Id {
-		return parser.ActualType{}, fail(statement, "Cannot combine types TODO ADD SUPPORT LATER")
+	if len(slicedType.ArraySizes) != 1 && slicedType.Id != parser.Slice && !isString(slicedType) {
+		return parser.ActualType{}, fail(expression.Left, "Can only slice slices, strings and one-dimensional arrays")
 	}
 
-	combinedType := leftType
+	bounds := []*big.Int{}
 
-	return combinedType, nil
+	for _, bound := range expression.Expressions {
+		if bound == nil {
+			continue
+		}
+
+		boundType, err := inferType(analyzer, bound, statement)
+
+		if err != nil {
+			return parser.ActualType{}, err
+		}
+
+		if !isInteger(boundType) {
+			return parser.ActualType{}, fail(bound, "Bounds of slice need to be integers")
+		}
+
+		value, isConstant := constantInteger(bound)
+
+		if isConstant && value.Sign() < 0 {
+			return parser.ActualType{}, fail(bound, fmt.Sprintf("Bound %s of slice is negative", value.String()))
+		}
+
+		if isConstant {
+			bounds = append(bounds, value)
+		}
+	}
+
+	// Constant bounds of arrays are checked against the size, slices and strings are checked at runtime
+	if len(slicedType.ArraySizes) > 0 {
+		size := big.NewInt(int64(slicedType.ArraySizes[0]))
+
+		for _, bound := range bounds {
+			if bound.Cmp(size) > 0 {
+				return parser.ActualType{}, fail(expression, fmt.Sprintf("Bound %s of slice is out of bounds of array with size %d", bound.String(), size))
+			}
+		}
+	}
+
+	if len(bounds) == 2 && bounds[0].Cmp(bounds[1]) > 0 {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Start %s of slice is after its end %s", bounds[0].String(), bounds[1].String()))
+	}
+
+	element := elementType(slicedType)
+
+	sliceType := parser.ActualType{Id: parser.Slice, ElementType: &element}
+
+	// Substring of bytes
+	if isString(slicedType) {
+		sliceType = slicedType
+	}
+
+	// Set context
+	expression.Types = []parser.ActualType{sliceType}
+	expression.ArgTypes = []parser.ActualType{slicedType}
+
+	return sliceType, nil
+}
+
+// Infers type of array literal from its values: [a, b, c]
+func inferArrayLiteralType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	valueTypes := []parser.ActualType{}
+
+	for _, value := range expression.Expressions {
+		valueType, err := inferType(analyzer, value, statement)
+
+		if err != nil {
+			return parser.ActualType{}, err
+		}
+
+		if valueType.Id == parser.Void {
+			return parser.ActualType{}, fail(value, "Values of array literal need a type")
+		}
+
+		valueTypes = append(valueTypes, valueType)
+	}
+
+	// Use the first type all values can be converted to
+	isCommon := func(candidate parser.ActualType) bool {
+		for i, value := range expression.Expressions {
+			if !convertImplicitly(nil, valueTypes[i], candidate) && !isUntypedNumberFitting(value, candidate) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	common := parser.ActualType{}
+
+	for _, valueType := range valueTypes {
+		if isCommon(valueType) {
+			common = valueType
+			break
+		}
+	}
+
+	if common.Id == parser.Void {
+		return parser.ActualType{}, fail(expression, "Values of array literal have mismatched types")
+	}
+
+	for i, value := range expression.Expressions {
+		convertImplicitly(value, valueTypes[i], common)
+
+		if err := validateStored(value, common); err != nil {
+			return parser.ActualType{}, err
+		}
+	}
+
+	arrayType := common
+	arrayType.ArraySizes = append([]int{len(expression.Expressions)}, common.ArraySizes...)
+
+	// Set context
+	expression.Types = []parser.ActualType{arrayType}
+
+	return arrayType, nil
+}
+
+// Returns the type of the elements of the array, slice or string type
+func elementType(arrayType parser.ActualType) parser.ActualType {
+	if arrayType.Id == parser.Slice {
+		return *arrayType.ElementType
+	}
+
+	// Strings are indexed by byte
+	if isString(arrayType) {
+		return parser.ActualType{Id: parser.UnsignedInt8}
+	}
+
+	element := arrayType
+	element.ArraySizes = arrayType.ArraySizes[1:]
+
+	if len(element.ArraySizes) == 0 {
+		element.ArraySizes = nil
+	}
+
+	return element
+}
+
+// Returns the value of an integer literal, which may be negated
+func constantInteger(expression *parser.Statement) (*big.Int, bool) {
+	if expression.Type == parser.UnaryExpression && expression.Unary == parser.NegateOperation {
+		value, isConstant := constantInteger(expression.Right)
+
+		if !isConstant {
+			return nil, false
+		}
+
+		return value.Neg(value), true
+	}
+
+	if expression.Type != parser.NumberLiteral {
+		return nil, false
+	}
+
+	value, ok := new(big.Int).SetString(expression.Value, 10)
+
+	return value, ok
+}
+
+// Validates conversion of the operand to the type of the expression: float64(x)
+func inferConversionType(analyzer *staticAnalyzer, expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	to := expression.Types[0]
+	from, err := inferType(analyzer, expression.Right, statement)
+
+	if err != nil {
+		return parser.ActualType{}, err
+	}
+
+	expression.ArgTypes = []parser.ActualType{from}
+
+	if !isConvertible(from, to) {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Cannot convert type %s to %s", from, to))
+	}
+
+	return to, nil
+}
+
+// Checks if type from can be converted explicitly to type to.
+// Numbers convert to each other and bool, complex numbers only to complex numbers.
+// Numbers besides complex ones and bool are formatted as string.
+// Runes convert to integers holding their code point and are encoded as string.
+func isConvertible(from parser.ActualType, to parser.ActualType) bool {
+	if len(from.ArraySizes) > 0 || len(to.ArraySizes) > 0 {
+		return false
+	}
+
+	if from.Id == to.Id {
+		return to.Id != parser.Void && to.Id != parser.Custom
+	}
+
+	isComplex := func(aType parser.ActualType) bool {
+		return aType.Id == parser.Complex64 || aType.Id == parser.Complex128
+	}
+
+	if isNumeric(from) && isNumeric(to) {
+		return !isComplex(from) || isComplex(to)
+	}
+
+	if isBool(from) && isNumeric(to) {
+		return !isComplex(to)
+	}
+
+	if isNumeric(from) && isBool(to) {
+		return !isComplex(from)
+	}
+
+	if (isNumeric(from) || isBool(from)) && isString(to) {
+		return !isComplex(from)
+	}
+
+	if isRune(from) {
+		return isInteger(to) || isString(to)
+	}
+
+	if isRune(to) {
+		return isInteger(from)
+	}
+
+	return false
+}
+
+// Candidates of unsuffixed number literals, the first one fitting the value is used.
+// Floats are float64 as float32 would round most fractions, typed contexts narrow them.
+var integerLiteralTypes = []parser.TypeId{parser.Int32, parser.Int64, parser.UnsignedInt64}
+var floatLiteralTypes = []parser.TypeId{parser.Float64}
+
+// Infers type of number literal from its suffix or the smallest candidate fitting the value
+func inferNumberType(expression *parser.Statement, statement *parser.Statement) (parser.ActualType, error) {
+	value := expression.Value
+	floating := strings.ContainsAny(value, ".eE")
+
+	candidates := integerLiteralTypes
+	if floating {
+		candidates = floatLiteralTypes
+	}
+
+	if expression.Range != "" {
+		id := parser.NumberSuffixes[expression.Range]
+
+		if floating && isInteger(parser.ActualType{Id: id}) {
+			return parser.ActualType{}, fail(expression, fmt.Sprintf("Number literal %s%s has a fraction or exponent but integer suffix", value, expression.Range))
+		}
+
+		candidates = []parser.TypeId{id}
+	}
+
+	for _, id := range candidates {
+		if numberFits(value, id) {
+			return parser.ActualType{Id: id}, nil
+		}
+	}
+
+	if expression.Range != "" {
+		return parser.ActualType{}, fail(expression, fmt.Sprintf("Number literal %s out of range for suffix %s", value, expression.Range))
+	}
+
+	return parser.ActualType{}, fail(expression, fmt.Sprintf("Number literal %s out of range", value))
+}
+
+// Number literals without suffix take the type they are assigned to if the value fits
+func isUntypedNumberFitting(expression *parser.Statement, aType parser.ActualType) bool {
+	if expression == nil || expression.Type != parser.NumberLiteral || expression.Range != "" {
+		return false
+	}
+
+	return isNumeric(aType) && numberFits(expression.Value, aType.Id)
+}
+
+// Checks if the literal value can be represented by number type
+func numberFits(value string, id parser.TypeId) bool {
+	if id == parser.Float32 || id == parser.Float64 {
+		f, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return false
+		}
+
+		if id == parser.Float64 {
+			return true
+		}
+
+		// Reject values overflowing or flushed to zero by float32
+		return math.Abs(f) <= math.MaxFloat32 && (f == 0 || float32(f) != 0)
+	}
+
+	integer, ok := new(big.Int).SetString(value, 10)
+
+	if !ok {
+		return false
+	}
+
+	bits := map[parser.TypeId]uint{
+		parser.Int8: 8, parser.UnsignedInt8: 8,
+		parser.Int16: 16, parser.UnsignedInt16: 16,
+		parser.Int32: 32, parser.UnsignedInt32: 32,
+		parser.Int64: 64, parser.UnsignedInt64: 64,
+	}[id]
+
+	if isUnsigned(parser.ActualType{Id: id}) {
+		return integer.Sign() >= 0 && integer.BitLen() <= int(bits)
+	}
+
+	// Signed range is -2^(bits-1) to 2^(bits-1)-1
+	limit := new(big.Int).Lsh(big.NewInt(1), bits-1)
+	return integer.Cmp(new(big.Int).Neg(limit)) >= 0 && integer.Cmp(limit) < 0
+}
+
+func isNumeric(aType parser.ActualType) bool {
+	return aType.Id >= parser.Int8 && len(aType.ArraySizes) == 0
+}
+
+func isBool(aType parser.ActualType) bool {
+	return aType.Id == parser.Bool && len(aType.ArraySizes) == 0
+}
+
+func isRune(aType parser.ActualType) bool {
+	return aType.Id == parser.Rune && len(aType.ArraySizes) == 0
+}
+
+func isString(aType parser.ActualType) bool {
+	return aType.Id == parser.String && len(aType.ArraySizes) == 0
+}
+
+func isInteger(aType parser.ActualType) bool {
+	if !isNumeric(aType) {
+		return false
+	}
+
+	switch aType.Id {
+	case parser.Float32, parser.Float64, parser.Complex64, parser.Complex128:
+		return false
+	}
+
+	return true
+}
+
+func isUnsigned(aType parser.ActualType) bool {
+	switch aType.Id {
+	case parser.UnsignedInt8, parser.UnsignedInt16, parser.UnsignedInt32, parser.UnsignedInt64:
+		return true
+	}
+
+	return false
 }
@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"fmt"
+
+	"github.com/yonedash/comet/analysis"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Codes of diagnostics, E for errors and W for warnings. The hundreds
+// tell the stage reporting it, codes ending in 00 are the general ones.
+const (
+	CodeTokenize       = "E0100"
+	CodeSyntax         = "E0200"
+	CodeStatic         = "E0300"
+	CodeRedeclared     = "E0301"
+	CodeUnusedVariable = "E0302"
+	CodeCompile        = "E0400"
+	CodeUnreachable    = "W0300"
+)
+
+// Span of source with an optional message shown below its underline
+type Label struct {
+	Trace   analysis.SourceTrace
+	Message string
+}
+
+// Problem found in the source. The primary span is where the problem is,
+// secondary spans point to related code like the first declaration of a
+// name declared twice.
+type Diagnostic struct {
+	Severity  Severity
+	Code      string
+	Message   string
+	Primary   Label
+	Secondary []Label
+	Notes     []string
+}
+
+func New(severity Severity, code string, trace analysis.SourceTrace, message string) Diagnostic {
+	return Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Primary:  Label{Trace: trace},
+	}
+}
+
+// Adds the message to the underline of the primary span
+func (d Diagnostic) WithLabel(message string) Diagnostic {
+	d.Primary.Message = message
+	return d
+}
+
+// Adds related span, diagnostics are values so copies never share spans or notes
+func (d Diagnostic) WithSecondary(trace analysis.SourceTrace, message string) Diagnostic {
+	d.Secondary = append(append([]Label{}, d.Secondary...), Label{Trace: trace, Message: message})
+	return d
+}
+
+// Adds note printed after the source
+func (d Diagnostic) WithNote(note string) Diagnostic {
+	d.Notes = append(append([]string{}, d.Notes...), note)
+	return d
+}
+
+// Single line form without source: file:row:col: error[E0200]: message
+func (d Diagnostic) Error() string {
+	header := fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Message)
+
+	if d.Primary.Trace.Row <= 0 {
+		return header
+	}
+
+	return d.Primary.Trace.String() + ": " + header
+}
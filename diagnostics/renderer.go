@@ -0,0 +1,138 @@
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Prints diagnostics with the source lines of their spans:
+//
+//	error[E0300]: Undefined function twice
+//	 --> main.cl:5:20
+//	  |
+//	5 |     printf("%d\n", twice(21))
+//	  |                    ^^^^^^^^^
+type Renderer struct {
+	sources map[string][]string // Lines of each file, nil if it cannot be read
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{sources: map[string][]string{}}
+}
+
+// Registers source that cannot be read from its file, like stdin
+func (r *Renderer) AddSource(file string, text string) {
+	r.sources[file] = splitLines(text)
+}
+
+func (r *Renderer) Render(d Diagnostic) string {
+	labels := append([]Label{d.Primary}, d.Secondary...)
+
+	width := 0
+	for _, label := range labels {
+		width = max(width, len(strconv.Itoa(label.Trace.Row)))
+	}
+
+	margin := strings.Repeat(" ", width)
+	text := fmt.Sprintf("%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	for i, label := range labels {
+		trace := label.Trace
+
+		if trace.Row <= 0 {
+			continue
+		}
+
+		arrow, marker := ":::", "-"
+		if i == 0 {
+			arrow, marker = "-->", "^"
+		}
+
+		text += fmt.Sprintf("%s%s %s\n", margin, arrow, trace)
+
+		line, found := r.line(trace.File, trace.Row)
+
+		if !found {
+			continue
+		}
+
+		underline := underlineOf(line, label, marker)
+
+		if label.Message != "" {
+			underline += " " + label.Message
+		}
+
+		text += margin + " |\n"
+		text += fmt.Sprintf("%*d | %s\n", width, trace.Row, line)
+		text += margin + " | " + underline + "\n"
+	}
+
+	for _, note := range d.Notes {
+		text += fmt.Sprintf("%s = note: %s\n", margin, note)
+	}
+
+	return text
+}
+
+// Line of the file at row, files are read once when first needed
+func (r *Renderer) line(file string, row int) (string, bool) {
+	lines, found := r.sources[file]
+
+	if !found {
+		source, err := os.ReadFile(file)
+
+		if err == nil {
+			lines = splitLines(string(source))
+		}
+
+		r.sources[file] = lines
+	}
+
+	if row < 1 || row > len(lines) {
+		return "", false
+	}
+
+	return lines[row-1], true
+}
+
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
+
+// Marks the span below the line, spans over multiple lines are marked to the end of the first
+func underlineOf(line string, label Label, marker string) string {
+	runes := []rune(line)
+	trace := label.Trace
+
+	start := min(max(trace.Column-1, 0), len(runes))
+	end := len(runes)
+
+	if trace.EndRow == trace.Row {
+		end = min(trace.EndColumn-1, len(runes))
+	}
+
+	// Spans at the end of the line are marked after its last character
+	if end <= start {
+		end = start + 1
+	}
+
+	// Tabs are kept so the marker lines up with the source
+	padding := ""
+	for _, ch := range runes[:start] {
+		if ch == '\t' {
+			padding += "\t"
+		} else {
+			padding += " "
+		}
+	}
+
+	return padding + strings.Repeat(marker, end-start)
+}
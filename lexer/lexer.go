@@ -3,24 +3,37 @@ package lexer
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/yonedash/comet/analysis"
+	"github.com/yonedash/comet/diagnostics"
 )
 
 type TokenizeError struct {
-	message string
+	diagnostic diagnostics.Diagnostic
 }
 
 func (e TokenizeError) Error() string {
-	return e.message
+	return e.diagnostic.Error()
+}
+
+func (e TokenizeError) Unwrap() error {
+	return e.diagnostic
 }
 
 type tokenReader struct {
-	text   []rune
-	length int
-	index  int
+	name    string
+	options analysis.Options
+	text    []rune
+	length  int
+	index   int
 }
 
 func (r tokenReader) at(i int) rune {
@@ -49,34 +62,48 @@ func (r *tokenReader) consume() rune {
 	return r.at(i)
 }
 
-func (r tokenReader) isDone() bool {
-	return r.index >= r.length
+// Creates error referring to the rune at index in source
+func (r tokenReader) errorAt(index int, message string) TokenizeError {
+	return r.errorBetween(index, index+1, message)
 }
 
-func getTokenReader(path string) (tokenReader, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return tokenReader{}, err
-	}
+// Creates error referring to the runes from start to end in source
+func (r tokenReader) errorBetween(start int, end int, message string) TokenizeError {
+	lineStarts := getLineStarts(r)
+	trace := analysis.SourceTrace{File: r.name, Index: start, End: end}
+	trace.Row, trace.Column = getLocationOfIndex(start, lineStarts)
+	trace.EndRow, trace.EndColumn = getLocationOfIndex(end, lineStarts)
 
-	defer file.Close()
+	return TokenizeError{diagnostic: diagnostics.New(diagnostics.Error, diagnostics.CodeTokenize, trace, message)}
+}
+
+func (r tokenReader) isDone() bool {
+	return r.index >= r.length
+}
 
-	bufReader := bufio.NewReader(file)
+func getTokenReader(name string, source io.Reader, options analysis.Options) (tokenReader, error) {
+	bufReader := bufio.NewReader(source)
 
 	text := []rune{}
 
 	for {
 		r, _, err := bufReader.ReadRune()
-		if err != nil {
+		if err == io.EOF {
 			break
 		}
 
+		if err != nil {
+			return tokenReader{}, err
+		}
+
 		text = append(text, r)
 	}
 
 	reader := tokenReader{
-		text:  text,
-		index: 0,
+		name:    name,
+		options: options,
+		text:    text,
+		index:   0,
 	}
 
 	reader.length = len(reader.text)
@@ -84,18 +111,57 @@ func getTokenReader(path string) (tokenReader, error) {
 	return reader, nil
 }
 
-func Tokenize(path string) ([]Token, error) {
-	reader, err := getTokenReader(path)
+// Tokenizes the file at path
+func Tokenize(path string, options analysis.Options) ([]Token, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return TokenizeSource(path, file, options)
+}
+
+// Tokenizes source held in memory, name is used to refer to it in errors
+func TokenizeString(name string, source string, options analysis.Options) ([]Token, error) {
+	return TokenizeSource(name, strings.NewReader(source), options)
+}
+
+// Tokenizes everything read from source, name is used to refer to it in errors
+func TokenizeSource(name string, source io.Reader, options analysis.Options) ([]Token, error) {
+	reader, err := getTokenReader(name, source, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := tokenize(&reader)
 
 	if err != nil {
 		return nil, err
 	}
 
+	tokens = append(tokens, Token{
+		Type: EOF,
+		Trace: &analysis.SourceTrace{
+			Index: reader.length - 1,
+			End:   reader.length,
+		},
+	})
+
+	fillTraces(tokens, reader)
+
+	return tokens, nil
+}
+
+// Tokenizes the text of reader up to its length, embedded expressions of strings use this too
+func tokenize(reader *tokenReader) ([]Token, error) {
 	tokens := []Token{}
 
 	identifier := ""
 
-	isComment := 0
+	docs := []docComment{}
 
 	for {
 		if reader.isDone() {
@@ -104,162 +170,263 @@ func Tokenize(path string) ([]Token, error) {
 
 		ch := reader.current()
 
-		// Skip comments
-		if ch == '/' && reader.after() == '/' {
+		// Skip comments, doc comments are kept for the token following them
+		if ch == '/' && (reader.after() == '/' || reader.after() == '*') {
 			safelyEndIdentifier(&identifier, &tokens, reader.index)
 
-			reader.index += 2
-			isComment = 1
+			doc, isDoc, err := comment(reader)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if isDoc {
+				docs = append(docs, docComment{token: len(tokens), text: doc})
+			}
+
 			continue
 		}
 
-		if ch == '/' && reader.after() == '*' {
+		reader.options.Debugf("lexer: %q %d idf-> %s <- %d", ch, reader.index, identifier, len(identifier))
+
+		// Check for string
+		if ch == '"' {
 			safelyEndIdentifier(&identifier, &tokens, reader.index)
+			strTokens, err := str(reader, reader.index)
 
-			reader.index += 2
-			isComment = 2
-			continue
-		}
+			if err != nil {
+				return nil, err
+			}
 
-		if isComment == 1 && ch == '\n' {
-			isComment = 0
-			reader.consume()
+			tokens = append(tokens, strTokens...)
 			continue
 		}
 
-		if isComment == 2 && ch == '*' && reader.after() == '/' {
-			isComment = 0
-			reader.index += 2
-			continue
-		}
+		if ch == '\'' {
+			safelyEndIdentifier(&identifier, &tokens, reader.index)
+			token, err := character(reader, reader.index)
 
-		if isComment > 0 {
-			reader.consume()
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token)
 			continue
 		}
 
-		fmt.Println(string(ch), ch, reader.index, "idf->", identifier, "<-", len(identifier))
-
-		// Check for string
-		if ch == '"' {
+		if ch == '`' {
 			safelyEndIdentifier(&identifier, &tokens, reader.index)
-			tokens = append(tokens, str(&reader, reader.index))
+			token, err := rawStr(reader, reader.index)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token)
 			continue
 		}
 
 		if ch == '-' && reader.after() == '>' {
-			appendType(ArrowRight, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume()))
+			appendType(ArrowRight, &identifier, &tokens, reader, 2)
 			continue
 		}
 
 		// Only make token a number if there was no identifier started
-		if len(identifier) == 0 && (unicode.IsDigit(ch) || ch == '.' || ch == '-') {
-			tokens = append(tokens, number(&reader, reader.index))
+		if len(identifier) == 0 && (unicode.IsDigit(ch) || (ch == '.' && unicode.IsDigit(reader.after()))) {
+			token, err := number(reader, reader.index)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token)
 			continue
 		}
 
 		if ch == '\n' {
-			appendType(LF, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(LF, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == ';' {
-			appendType(Semicolon, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Semicolon, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == ':' {
-			appendType(Colon, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Colon, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == ',' {
-			appendType(Comma, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Comma, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '(' {
-			appendType(OpenParenthesis, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(OpenParenthesis, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == ')' {
-			appendType(CloseParenthesis, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(CloseParenthesis, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '{' {
-			appendType(OpenCurlyBracket, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(OpenCurlyBracket, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '}' {
-			appendType(CloseCurlyBracket, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(CloseCurlyBracket, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '[' {
-			appendType(OpenSquareBracket, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(OpenSquareBracket, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == ']' {
-			appendType(CloseSquareBracket, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(CloseSquareBracket, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '+' && reader.after() == '+' {
+			appendType(Increment, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '-' && reader.after() == '-' {
+			appendType(Decrement, &identifier, &tokens, reader, 2)
 			continue
 		}
 
+		if reader.after() == '=' {
+			if tokenType, found := compoundAssignments[ch]; found {
+				appendType(tokenType, &identifier, &tokens, reader, 2)
+				continue
+			}
+		}
+
 		if ch == '+' {
-			appendType(Addition, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Addition, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '-' {
-			appendType(Subtraction, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Subtraction, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '*' {
-			appendType(Multiplication, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Multiplication, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '/' {
-			appendType(Division, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Division, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '%' {
-			appendType(Modulus, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Modulus, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '=' && reader.after() == '=' {
-			appendType(CompareEquals, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume()))
+			appendType(CompareEquals, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '!' && reader.after() == '=' {
+			appendType(CompareNotEquals, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '<' && reader.after() == '<' {
+			appendType(ShiftLeft, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '>' && reader.after() == '>' {
+			appendType(ShiftRight, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '<' && reader.after() == '=' {
+			appendType(CompareSmallerEquals, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '>' && reader.after() == '=' {
+			appendType(CompareBiggerEquals, &identifier, &tokens, reader, 2)
 			continue
 		}
 
-		if ch == '=' && reader.after() == '<' {
-			appendType(CompareSmaller, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume()))
+		if ch == '<' {
+			appendType(CompareSmaller, &identifier, &tokens, reader, 1)
 			continue
 		}
 
-		if ch == '=' && reader.after() == '>' {
-			appendType(CompareBigger, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume()))
+		if ch == '>' {
+			appendType(CompareBigger, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '&' && reader.after() == '&' {
+			appendType(LogicalAnd, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '|' && reader.after() == '|' {
+			appendType(LogicalOr, &identifier, &tokens, reader, 2)
+			continue
+		}
+
+		if ch == '!' {
+			appendType(LogicalNot, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '&' {
+			appendType(BitwiseAnd, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '|' {
+			appendType(BitwiseOr, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '^' {
+			appendType(BitwiseXor, &identifier, &tokens, reader, 1)
+			continue
+		}
+
+		if ch == '~' {
+			appendType(BitwiseNot, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '=' {
-			appendType(Equals, &identifier, &tokens, reader.index, string(reader.consume()))
+			appendType(Equals, &identifier, &tokens, reader, 1)
 			continue
 		}
 
 		if ch == '.' && reader.after() == '.' && reader.at(reader.index+2) == '.' {
-			appendType(Variadic, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume())+string(reader.consume()))
+			appendType(Variadic, &identifier, &tokens, reader, 3)
 			continue
 		}
 
 		if ch == '.' && reader.after() == '.' && reader.at(reader.index+2) == '?' {
-			appendType(VariadicNoValidate, &identifier, &tokens, reader.index, string(reader.consume())+string(reader.consume())+string(reader.consume()))
+			appendType(VariadicNoValidate, &identifier, &tokens, reader, 3)
+			continue
+		}
+
+		if ch == '.' {
+			appendType(Dot, &identifier, &tokens, reader, 1)
 			continue
 		}
 
@@ -270,95 +437,95 @@ func Tokenize(path string) ([]Token, error) {
 			continue
 		}
 
-		// Indentifiers must start with letter and can then contain digit or .
-		if unicode.IsLetter(ch) || (len(identifier) != 0 && (unicode.IsDigit(ch) || ch == '.')) {
+		// Indentifiers must start with letter and can then contain digit
+		if unicode.IsLetter(ch) || (len(identifier) != 0 && unicode.IsDigit(ch)) {
 			identifier += string(reader.consume())
 			continue
 		}
 
 		// Return error if unknown character is in source
-		lineFeeds := getLineFeeds(reader)
-		row, col := getLocationOfIndex(reader.index, lineFeeds)
-
-		msg := fmt.Sprintf("Unknown character @ %d:%d '%s'", row, col, string(ch))
-
-		return nil, TokenizeError{message: msg}
+		return nil, reader.errorAt(reader.index, fmt.Sprintf("Unknown character '%s'", string(ch)))
 	}
 
 	// End possible missing identifier
 	safelyEndIdentifier(&identifier, &tokens, reader.length)
 
-	tokens = append(tokens, Token{
-		Type: EOF,
-		Trace: &analysis.SourceTrace{
-			Index: reader.length - 1,
-		},
-	})
-
-	fillTraces(tokens, reader)
+	attachDocs(tokens, docs)
 
 	return tokens, nil
 }
 
+// Operator characters followed by = to assign
+var compoundAssignments = map[rune]TokenType{
+	'+': AdditionEquals,
+	'-': SubtractionEquals,
+	'*': MultiplicationEquals,
+	'/': DivisionEquals,
+	'%': ModulusEquals,
+}
+
 func fillTraces(tokens []Token, reader tokenReader) {
-	lineFeeds := getLineFeeds(reader)
+	lineStarts := getLineStarts(reader)
 
 	for _, token := range tokens {
-		row, col := getLocationOfIndex(token.Trace.Index, lineFeeds)
 		trace := token.Trace
-		trace.Row = row
-		trace.Column = col
+		trace.File = reader.name
+		trace.Row, trace.Column = getLocationOfIndex(trace.Index, lineStarts)
+		trace.EndRow, trace.EndColumn = getLocationOfIndex(trace.End, lineStarts)
 	}
-
 }
 
-func getLineFeeds(reader tokenReader) []int {
-	lineFeeds := []int{}
-
-	lineFeeds = append(lineFeeds, 0)
+// Indices of the first rune of each line
+func getLineStarts(reader tokenReader) []int {
+	lineStarts := []int{0}
 
 	for i := 0; i < reader.length; i++ {
-		ch := reader.text[i]
-
-		if ch == '\n' {
-			lineFeeds = append(lineFeeds, i)
+		if reader.text[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
 		}
-
 	}
 
-	return lineFeeds
+	return lineStarts
 }
 
-func getLocationOfIndex(index int, lineFeeds []int) (int, int) {
-	len := len(lineFeeds)
-	for i := 0; i < len; i++ {
-		lf := lineFeeds[i]
-
-		if lf <= index && (i == len-1 || lineFeeds[i+1] > index) {
-			col := index - lf
-
-			if i == 0 {
-				col++
-			}
+// Row and column of index, a line feed is the last rune of its line
+func getLocationOfIndex(index int, lineStarts []int) (int, int) {
+	row := sort.Search(len(lineStarts), func(i int) bool {
+		return lineStarts[i] > index
+	})
 
-			return i + 1, col
-		}
+	if row == 0 {
+		return -1, -1
 	}
-	return -1, -1
+
+	return row, index - lineStarts[row-1] + 1
 }
 
 func isWhitespace(ch rune) bool {
 	return ch == ' ' || ch == '\n' || ch == '\r' || ch == '\t'
 }
 
-func appendType(tokenType TokenType, identifier *string, tokens *[]Token, index int, value string) {
+// Appends token of the next n runes of reader, a pending identifier is ended before
+func appendType(tokenType TokenType, identifier *string, tokens *[]Token, reader *tokenReader, n int) {
+	index := reader.index
 	safelyEndIdentifier(identifier, tokens, index)
 
+	value := ""
+
+	for i := 0; i < n; i++ {
+		value += string(reader.consume())
+	}
+
+	appendToken(tokenType, tokens, index, value)
+}
+
+func appendToken(tokenType TokenType, tokens *[]Token, index int, value string) {
 	*tokens = append(*tokens, Token{
 		Type:  tokenType,
 		Value: value,
 		Trace: &analysis.SourceTrace{
 			Index: index,
+			End:   index + utf8.RuneCountInString(value),
 		},
 	})
 }
@@ -372,7 +539,7 @@ func safelyEndIdentifier(identifier *string, tokens *[]Token, index int) {
 
 	// Check for keywords in identifier
 	if tokenType, found := Keywords[identifierDeref]; found {
-		appendType(tokenType, nil, tokens, index-len(identifierDeref), identifierDeref)
+		appendToken(tokenType, tokens, index-utf8.RuneCountInString(identifierDeref), identifierDeref)
 		*identifier = ""
 		return
 	}
@@ -381,70 +548,541 @@ func safelyEndIdentifier(identifier *string, tokens *[]Token, index int) {
 		Type:  Identifier,
 		Value: identifierDeref,
 		Trace: &analysis.SourceTrace{
-			Index: index - len(identifierDeref),
+			Index: index - utf8.RuneCountInString(identifierDeref),
+			End:   index,
 		},
 	})
 	*identifier = ""
 }
 
-func str(reader *tokenReader, index int) Token {
+type docComment struct {
+	token int // Index of the token following the comment
+	text  string
+}
+
+// Skips the comment at reader, // to the end of the line or /* */ which may nest.
+// Returns the text of doc comments: /// line or /** block */
+func comment(reader *tokenReader) (string, bool, error) {
+	start := reader.index
+
+	// Line feed is kept as token
+	if reader.after() == '/' {
+		reader.index += 2
+		isDoc := reader.current() == '/' && reader.after() != '/'
+
+		text := ""
+
+		for !reader.isDone() && reader.current() != '\n' {
+			text += string(reader.consume())
+		}
+
+		if !isDoc {
+			return "", false, nil
+		}
+
+		return strings.TrimPrefix(strings.TrimSuffix(text[1:], "\r"), " "), true, nil
+	}
+
+	reader.index += 2
+	isDoc := reader.current() == '*' && reader.after() != '*' && reader.after() != '/'
+
+	text := ""
+	depth := 1
+
+	for depth > 0 {
+		if reader.isDone() {
+			return "", false, reader.errorAt(start, "Unterminated block comment")
+		}
+
+		ch := reader.current()
+
+		if ch == '/' && reader.after() == '*' {
+			depth++
+			text += string(reader.consume()) + string(reader.consume())
+			continue
+		}
+
+		if ch == '*' && reader.after() == '/' {
+			depth--
+			reader.index += 2
+
+			if depth > 0 {
+				text += "*/"
+			}
+
+			continue
+		}
+
+		text += string(reader.consume())
+	}
+
+	if !isDoc {
+		return "", false, nil
+	}
+
+	// Leading * of each line is not part of the text
+	lines := strings.Split(text[1:], "\n")
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		line = strings.TrimPrefix(line, "*")
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n"), true, nil
+}
+
+// Attaches doc comments to the token following them besides line feeds, doc comments of the same token are joined
+func attachDocs(tokens []Token, docs []docComment) {
+	for _, doc := range docs {
+		i := doc.token
+
+		for i < len(tokens) && tokens[i].Type == LF {
+			i++
+		}
+
+		if i == len(tokens) {
+			continue
+		}
+
+		if tokens[i].Doc != "" {
+			tokens[i].Doc += "\n"
+		}
+
+		tokens[i].Doc += doc.text
+	}
+}
+
+// Values of strings hold the decoded bytes, escape sequences are \n \t \r \0 \\ \" \' \$ \xNN and \u{N...}.
+// Expressions embedded with ${...} follow an Interpolation token holding the text before them,
+// the text after the last one is a String token: Interpolation("a") x Interpolation("b") y String("c")
+func str(reader *tokenReader, index int) ([]Token, error) {
 	// Consume "
 	reader.consume()
 
-	value := ""
+	tokens := []Token{}
+	value := []byte{}
+	start := index
 
 	for {
-		ch := reader.current()
-
 		if reader.isDone() {
+			return nil, reader.errorAt(index, "Unterminated string literal")
+		}
+
+		ch := reader.consume()
+
+		if ch == '"' {
 			break
 		}
 
-		// \" \\"
-		if (ch == '"' && reader.before() != '\\') || (ch == '"' && reader.before() == '\\' && reader.at(reader.index-2) == '\\') {
-			reader.consume()
+		if ch == '$' && reader.current() == '{' {
+			tokens = append(tokens, Token{
+				Type:  Interpolation,
+				Value: string(value),
+				Trace: &analysis.SourceTrace{
+					Index: start,
+					End:   reader.index + 1,
+				},
+			})
+
+			embeddedTokens, err := embedded(reader)
+
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, embeddedTokens...)
+			value = []byte{}
+			start = reader.index
+			continue
+		}
+
+		if ch != '\\' {
+			value = utf8.AppendRune(value, ch)
+			continue
+		}
+
+		decoded, err := escape(reader)
+
+		if err != nil {
+			return nil, err
+		}
+
+		value = append(value, decoded...)
+	}
+
+	token := Token{
+		Type:  String,
+		Value: string(value),
+		Trace: &analysis.SourceTrace{
+			Index: start,
+			End:   reader.index,
+		},
+	}
+	return append(tokens, token), nil
+}
+
+// Tokenizes the expression embedded in a string, reader is at the { after $
+func embedded(reader *tokenReader) ([]Token, error) {
+	start := reader.index
+
+	end := reader.interpolationEnd(start + 1)
+
+	if end < 0 {
+		return nil, reader.errorAt(start-1, "Unterminated ${ in string literal")
+	}
+
+	// Embedded expressions are tokenized in place, so their traces point into the string
+	expressionReader := *reader
+	expressionReader.index = start + 1
+	expressionReader.length = end
+
+	tokens, err := tokenize(&expressionReader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reader.index = end + 1
+
+	// Expressions may be wrapped over lines
+	expression := []Token{}
+
+	for _, token := range tokens {
+		if token.Type != LF {
+			expression = append(expression, token)
+		}
+	}
+
+	if len(expression) == 0 {
+		return nil, reader.errorAt(start-1, "Interpolation ${} needs an expression")
+	}
+
+	return expression, nil
+}
+
+// Finds the } closing the expression embedded at i or -1, strings in the expression may embed expressions themselves
+func (r tokenReader) interpolationEnd(i int) int {
+	depth := 1
+
+	for ; i < r.length; i++ {
+		switch r.at(i) {
+		case '{':
+			depth++
+		case '}':
+			depth--
+
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			i = r.stringEnd(i + 1)
+		case '`':
+			for i++; i < r.length && r.at(i) != '`'; i++ {
+			}
+		}
+	}
+
+	return -1
+}
+
+// Finds the " closing the string starting at i or the length of the text
+func (r tokenReader) stringEnd(i int) int {
+	for ; i < r.length; i++ {
+		switch r.at(i) {
+		case '\\':
+			i++
+		case '"':
+			return i
+		case '$':
+			if r.at(i+1) != '{' {
+				continue
+			}
+
+			i = r.interpolationEnd(i + 2)
+
+			if i < 0 {
+				return r.length
+			}
+		}
+	}
+
+	return r.length
+}
+
+// Character literals hold exactly one code point: 'a', '\n', '\u{1F600}'
+func character(reader *tokenReader, index int) (Token, error) {
+	// Consume '
+	reader.consume()
+
+	value := []byte{}
+
+	for {
+		if reader.isDone() || reader.current() == '\n' {
+			return Token{}, reader.errorAt(index, "Unterminated character literal")
+		}
+
+		ch := reader.consume()
+
+		if ch == '\'' {
+			break
+		}
+
+		if ch != '\\' {
+			value = utf8.AppendRune(value, ch)
+			continue
+		}
+
+		decoded, err := escape(reader)
+
+		if err != nil {
+			return Token{}, err
+		}
+
+		value = append(value, decoded...)
+	}
+
+	if r, size := utf8.DecodeRune(value); len(value) == 0 || size != len(value) || (r == utf8.RuneError && size == 1) {
+		return Token{}, reader.errorAt(index, "Character literal must hold exactly one code point")
+	}
+
+	return Token{
+		Type:  Character,
+		Value: string(value),
+		Trace: &analysis.SourceTrace{
+			Index: index,
+			End:   reader.index,
+		},
+	}, nil
+}
+
+// Raw strings span lines without escape sequences: `C:\path`
+func rawStr(reader *tokenReader, index int) (Token, error) {
+	// Consume `
+	reader.consume()
+
+	value := ""
+
+	for {
+		if reader.isDone() {
+			return Token{}, reader.errorAt(index, "Unterminated raw string literal")
+		}
+
+		ch := reader.consume()
+
+		if ch == '`' {
 			break
 		}
 
+		// Line feeds are the same for files with CRLF line endings
+		if ch == '\r' && reader.current() == '\n' {
+			continue
+		}
+
 		value += string(ch)
-		reader.consume()
 	}
 
-	token := Token{
+	return Token{
 		Type:  String,
 		Value: value,
 		Trace: &analysis.SourceTrace{
 			Index: index,
+			End:   reader.index,
 		},
+	}, nil
+}
+
+// Decodes the escape sequence following \ to its bytes
+func escape(reader *tokenReader) ([]byte, error) {
+	start := reader.index - 1
+
+	switch ch := reader.consume(); ch {
+	case 'n':
+		return []byte{'\n'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case '0':
+		return []byte{0}, nil
+	case '\\', '"', '\'', '$':
+		return []byte{byte(ch)}, nil
+
+	case 'x':
+		digits := string(reader.consume()) + string(reader.consume())
+		value, err := strconv.ParseUint(digits, 16, 8)
+
+		if err != nil {
+			return nil, reader.errorBetween(start, reader.index, "Escape sequence \\x needs two hexadecimal digits")
+		}
+
+		return []byte{byte(value)}, nil
+
+	case 'u':
+		if reader.consume() != '{' {
+			return nil, reader.errorBetween(start, reader.index, "Escape sequence \\u needs a code point in braces: \\u{1F600}")
+		}
+
+		digits := ""
+
+		for !reader.isDone() && reader.current() != '}' && len(digits) <= 6 {
+			digits += string(reader.consume())
+		}
+
+		if reader.consume() != '}' {
+			return nil, reader.errorBetween(start, reader.index, "Escape sequence \\u needs a code point in braces: \\u{1F600}")
+		}
+
+		value, err := strconv.ParseUint(digits, 16, 32)
+
+		if err != nil || len(digits) > 6 {
+			return nil, reader.errorBetween(start, reader.index, fmt.Sprintf("Invalid code point '%s' in escape sequence \\u", digits))
+		}
+
+		if !utf8.ValidRune(rune(value)) {
+			return nil, reader.errorBetween(start, reader.index, fmt.Sprintf("Code point U+%X is no unicode scalar value", value))
+		}
+
+		return utf8.AppendRune(nil, rune(value)), nil
 	}
-	return token
+
+	return nil, reader.errorBetween(start, reader.index, fmt.Sprintf("Unknown escape sequence '\\%s'", string(reader.before())))
 }
 
-func number(reader *tokenReader, index int) Token {
+// Number literals: decimal (1, 1.5, .5, 1e-9), hexadecimal (0xFF), binary (0b1010)
+// and octal (0o17). Digits may be separated by _ and a type suffix may follow (10u8).
+// The value is normalized to decimal without separators, the suffix is kept apart.
+func number(reader *tokenReader, index int) (Token, error) {
 	value := ""
-	dots := 0
-	i := 0
-	for {
-		ch := reader.current()
+	base := 10
+
+	if reader.current() == '0' {
+		switch unicode.ToLower(reader.after()) {
+		case 'x':
+			base = 16
+		case 'b':
+			base = 2
+		case 'o':
+			base = 8
+		}
+	}
 
-		if i == 0 && ch == '-' {
-			value += string(reader.consume())
-		} else if dots == 0 && ch == '.' {
-			value += string(reader.consume())
-			dots++
-		} else if unicode.IsDigit(ch) {
+	if base != 10 {
+		reader.index += 2
+
+		digits, err := readDigits(reader, base)
+
+		if err != nil {
+			return Token{}, err
+		}
+
+		if digits == "" {
+			return Token{}, reader.errorAt(index, "Number literal without digits")
+		}
+
+		integer, _ := new(big.Int).SetString(digits, base)
+		value = integer.String()
+	} else {
+		digits, err := readDigits(reader, base)
+
+		if err != nil {
+			return Token{}, err
+		}
+
+		// Leading zeros would make C read the literal as octal
+		value = strings.TrimLeft(digits, "0")
+		if value == "" && digits != "" {
+			value = "0"
+		}
+
+		if reader.current() == '.' && unicode.IsDigit(reader.after()) {
+			reader.consume()
+
+			fraction, err := readDigits(reader, base)
+
+			if err != nil {
+				return Token{}, err
+			}
+
+			value += "." + fraction
+		}
+
+		// Exponent only if followed by digits, otherwise e is part of the suffix
+		sign := reader.after() == '+' || reader.after() == '-'
+		if unicode.ToLower(reader.current()) == 'e' && (unicode.IsDigit(reader.after()) || (sign && unicode.IsDigit(reader.at(reader.index+2)))) {
 			value += string(reader.consume())
-		} else {
-			break
+
+			if sign {
+				value += string(reader.consume())
+			}
+
+			exponent, err := readDigits(reader, base)
+
+			if err != nil {
+				return Token{}, err
+			}
+
+			value += exponent
 		}
-		i++
 	}
+
+	suffix := ""
+	for unicode.IsLetter(reader.current()) || unicode.IsDigit(reader.current()) || reader.current() == '_' {
+		suffix += string(reader.consume())
+	}
+
 	token := Token{
-		Type:  Number,
-		Value: value,
+		Type:   Number,
+		Value:  value,
+		Suffix: suffix,
 		Trace: &analysis.SourceTrace{
 			Index: index,
+			End:   reader.index,
 		},
 	}
-	return token
+	return token, nil
+}
+
+// Consumes digits of base, _ is only allowed between two digits
+func readDigits(reader *tokenReader, base int) (string, error) {
+	value := ""
+
+	for {
+		ch := reader.current()
+
+		if ch == '_' {
+			if value == "" || !isDigitOfBase(reader.after(), base) {
+				return "", reader.errorAt(reader.index, "Digit separator _ must be between digits")
+			}
+
+			reader.consume()
+			continue
+		}
+
+		if !isDigitOfBase(ch, base) {
+			break
+		}
+
+		value += string(reader.consume())
+	}
+
+	// Digits of a bigger base are an error, not the start of the suffix
+	if unicode.IsDigit(reader.current()) {
+		return "", reader.errorAt(reader.index, fmt.Sprintf("Invalid digit '%s' in base %d literal", string(reader.current()), base))
+	}
+
+	return value, nil
+}
+
+func isDigitOfBase(ch rune, base int) bool {
+	switch base {
+	case 2:
+		return ch == '0' || ch == '1'
+	case 8:
+		return ch >= '0' && ch <= '7'
+	case 16:
+		return unicode.IsDigit(ch) || (unicode.ToLower(ch) >= 'a' && unicode.ToLower(ch) <= 'f')
+	}
+
+	return unicode.IsDigit(ch)
 }
@@ -10,15 +10,36 @@ const (
 	Null // There will be no null in this language?
 	Number
 	String
+	Interpolation // Text of a string before an embedded expression
+	Character
 	Identifier
 	Boolean
-	Equals // add plus equals etc..
+	Equals
+	AdditionEquals
+	SubtractionEquals
+	MultiplicationEquals
+	DivisionEquals
+	ModulusEquals
+	Increment
+	Decrement
 	Colon
 	Semicolon
 	Comma
 	CompareEquals
+	CompareNotEquals
 	CompareSmaller
+	CompareSmallerEquals
 	CompareBigger
+	CompareBiggerEquals
+	LogicalAnd
+	LogicalOr
+	LogicalNot
+	BitwiseAnd
+	BitwiseOr
+	BitwiseXor
+	BitwiseNot
+	ShiftLeft
+	ShiftRight
 	OpenParenthesis
 	CloseParenthesis
 	OpenCurlyBracket
@@ -31,6 +52,7 @@ const (
 	Division
 	Modulus
 	ArrowRight
+	Dot
 	Variadic
 	VariadicNoValidate
 	Var // Keywords
@@ -38,21 +60,43 @@ const (
 	Function
 	Import
 	Native
+	If
+	Else
+	While
+	For
+	Break
+	Continue
+	Return
+	Struct
+	Enum
+	Match
 )
 
 var Keywords = map[string]TokenType{
-	"null":   Null,
-	"var":    Var,
-	"const":  Const,
-	"fn":     Function,
-	"true":   Boolean,
-	"false":  Boolean,
-	"import": Import,
-	"native": Native,
+	"null":     Null,
+	"var":      Var,
+	"const":    Const,
+	"fn":       Function,
+	"true":     Boolean,
+	"false":    Boolean,
+	"import":   Import,
+	"native":   Native,
+	"if":       If,
+	"else":     Else,
+	"while":    While,
+	"for":      For,
+	"break":    Break,
+	"continue": Continue,
+	"return":   Return,
+	"struct":   Struct,
+	"enum":     Enum,
+	"match":    Match,
 }
 
 type Token struct {
-	Type  TokenType
-	Value string
-	Trace *analysis.SourceTrace
+	Type   TokenType
+	Value  string
+	Suffix string // Type suffix of Number (u8, f64 etc.)
+	Doc    string // Text of doc comments before the token
+	Trace  *analysis.SourceTrace
 }
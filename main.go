@@ -1,69 +1,527 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/yonedash/comet/analysis"
 	"github.com/yonedash/comet/compiler"
 	"github.com/yonedash/comet/context"
+	"github.com/yonedash/comet/diagnostics"
 	"github.com/yonedash/comet/lexer"
 	"github.com/yonedash/comet/parser"
 )
 
+// Exit codes of the comet command
+const (
+	exitOk = iota
+	exitFailure
+	exitUsage
+	exitTokenizeError
+	exitParseError
+	exitStaticError
+	exitCompileError
+	exitCCompileError
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+var commands []command
+
+// Renders errors and warnings with their source, stdin is registered once read
+var renderer = diagnostics.NewRenderer()
+
+func init() {
+	commands = []command{
+		{"build", "[-o output] [-cc compiler] [-tokens] [-ast] [-debug] files...", "compile files to an executable", runBuild},
+		{"run", "[-cc compiler] [-tokens] [-ast] [-debug] files... [-- arguments...]", "compile and run files", runRun},
+		{"check", "[-tokens] [-ast] [-debug] files...", "run the static analysis on files without compiling", runCheck},
+		{"tokens", "[-debug] files...", "print the tokens of files", runTokens},
+		{"ast", "[-populated] [-debug] files...", "print the syntax tree of files", runAst},
+		{"emit-c", "[-o output.c] [-debug] files...", "print the compiled C source of files", runEmitC},
+	}
+}
+
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
 func main() {
-	tokens, err := lexer.Tokenize("test.cl")
+	os.Exit(execute(os.Args[1:]))
+}
+
+func execute(args []string) int {
+	if len(args) == 0 {
+		printUsage()
+		return exitUsage
+	}
+
+	name := args[0]
+
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage()
+		return exitOk
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+
+		if err == nil {
+			return exitOk
+		}
+
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+
+		// Exit status of the program started by run is forwarded silently,
+		// a crash is reported since the program could not report it itself
+		var statusErr exitStatusError
+		if errors.As(err, &statusErr) {
+			if statusErr.signal != 0 {
+				fmt.Fprintln(os.Stderr, statusErr.Error())
+			}
+
+			return statusErr.code
+		}
+
+		// Errors in the source are shown with the lines they refer to
+		var diagnostic diagnostics.Diagnostic
+		if errors.As(err, &diagnostic) {
+			fmt.Fprint(os.Stderr, renderer.Render(diagnostic))
+			return exitCodeOf(err)
+		}
+
+		// Flag errors are already reported by the flag set
+		var usageErr usageError
+		if !errors.As(err, &usageErr) || usageErr.message != "" {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		return exitCodeOf(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %s\n", name)
+	printUsage()
+
+	return exitUsage
+}
+
+func exitCodeOf(err error) int {
+	var usageErr usageError
+	var tokenizeErr lexer.TokenizeError
+	var parseErr parser.ParseError
+	var staticErr context.StaticError
+	var compileErr compiler.CompileError
+	var cCompileErr cCompileError
+
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &tokenizeErr):
+		return exitTokenizeError
+	case errors.As(err, &parseErr):
+		return exitParseError
+	case errors.As(err, &staticErr):
+		return exitStaticError
+	case errors.As(err, &compileErr):
+		return exitCompileError
+	case errors.As(err, &cCompileErr):
+		return exitCCompileError
+	}
+
+	return exitFailure
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: comet <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	flags.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(flags.Output(), "Usage: comet %s %s\n", cmd.name, cmd.usage)
+			}
+		}
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	err := flags.Parse(args)
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, usageError{}
 	}
 
+	files := flags.Args()
+
+	if len(files) == 0 {
+		flags.Usage()
+		return nil, usageError{message: "No input files"}
+	}
+
+	return files, nil
+}
+
+// Debug dumps of the pipeline, enabled by flags
+type dumps struct {
+	tokens bool
+	ast    bool
+	debug  bool
+}
+
+func (d *dumps) register(flags *flag.FlagSet) {
+	flags.BoolVar(&d.tokens, "tokens", false, "print tokens")
+	flags.BoolVar(&d.ast, "ast", false, "print syntax tree before and after analysis")
+	flags.BoolVar(&d.debug, "debug", false, "print debug output of all stages to stderr")
+}
+
+func (d dumps) options() analysis.Options {
+	if !d.debug {
+		return analysis.Options{}
+	}
+
+	return analysis.Options{Logger: log.New(os.Stderr, "", 0)}
+}
+
+// Tokenizes a file, - reads from stdin
+func tokenizeFile(path string, options analysis.Options) ([]lexer.Token, error) {
+	if path == "-" {
+		source, err := io.ReadAll(os.Stdin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		renderer.AddSource("<stdin>", string(source))
+
+		return lexer.TokenizeSource("<stdin>", bytes.NewReader(source), options)
+	}
+
+	return lexer.Tokenize(path, options)
+}
+
+// Tokenizes all files as one continuous token stream
+func tokenizeFiles(paths []string, options analysis.Options) ([]lexer.Token, error) {
+	tokens := []lexer.Token{}
+
+	for i, path := range paths {
+		fileTokens, err := tokenizeFile(path, options)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Replace EOF between files with a line feed to end the last statement
+		if i != len(paths)-1 && len(fileTokens) > 0 {
+			last := &fileTokens[len(fileTokens)-1]
+			last.Type = lexer.LF
+			last.Value = "\n"
+		}
+
+		tokens = append(tokens, fileTokens...)
+	}
+
+	return tokens, nil
+}
+
+// Runs lexer, parser and static analysis on files
+func analyzeFiles(paths []string, d dumps) (parser.Statement, error) {
+	options := d.options()
+	tokens, err := tokenizeFiles(paths, options)
+
+	if err != nil {
+		return parser.Statement{}, err
+	}
+
+	if d.tokens {
+		printTokens(tokens)
+	}
+
+	root, err := parser.ParseTokens(tokens, options)
+
+	if err != nil {
+		return parser.Statement{}, err
+	}
+
+	if d.ast {
+		fmt.Println("BEFORE POPULATION")
+		parser.PrintAST(root, 0)
+	}
+
+	hints, err := context.Grow(&root, options)
+
+	if d.ast {
+		fmt.Println("AFTER POPULATION")
+		parser.PrintAST(root, 0)
+	}
+
+	for _, hint := range hints {
+		fmt.Fprint(os.Stderr, renderer.Render(hint))
+	}
+
+	if err != nil {
+		return parser.Statement{}, err
+	}
+
+	return root, nil
+}
+
+func compileFiles(paths []string, d dumps) (string, error) {
+	root, err := analyzeFiles(paths, d)
+
+	if err != nil {
+		return "", err
+	}
+
+	return compiler.CompileC(&root, d.options())
+}
+
+func printTokens(tokens []lexer.Token) {
 	for _, token := range tokens {
-		fmt.Println(token)
+		trace := token.Trace
+		fmt.Printf("%d:%d-%d:%d %d %q\n", trace.Row, trace.Column, trace.EndRow, trace.EndColumn, token.Type, token.Value)
+	}
+}
+
+// Default output path: first input with its extension replaced
+func defaultOutput(paths []string, extension string) string {
+	path := paths[0]
+
+	if path == "-" {
+		path = "main"
 	}
 
-	statement, err := parser.ParseTokens(tokens)
+	return strings.TrimSuffix(path, filepath.Ext(path)) + extension
+}
+
+func runBuild(args []string) error {
+	flags := newFlagSet("build")
+	output := flags.String("o", "", "output path (default: first input without extension)")
+	ccFlag := flags.String("cc", "", "C compiler (default: $CC or cc, gcc, clang from PATH)")
+	d := dumps{}
+	d.register(flags)
+
+	files, err := parseFlags(flags, args)
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	fmt.Println("BEFORE POPULATION")
-	parser.PrintAST(statement, 0)
+	cc, err := findCCompiler(*ccFlag)
 
-	hints, err := context.Grow(&statement)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("AFTER POPULATION")
-	parser.PrintAST(statement, 0)
+	c, err := compileFiles(files, d)
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	for _, hint := range hints {
-		fmt.Println(hint.Message, hint.Statement.Trace)
+	if *output == "" {
+		*output = defaultOutput(files, "")
+	}
+
+	return compileNative(cc, c, *output)
+}
+
+func runRun(args []string) error {
+	flags := newFlagSet("run")
+	ccFlag := flags.String("cc", "", "C compiler (default: $CC or cc, gcc, clang from PATH)")
+	d := dumps{}
+	d.register(flags)
+
+	files, err := parseFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	// Arguments after -- are passed to the program
+	programArgs := []string{}
+	for i, file := range files {
+		if file == "--" {
+			programArgs = files[i+1:]
+			files = files[:i]
+			break
+		}
+	}
+
+	if len(files) == 0 {
+		flags.Usage()
+		return usageError{message: "No input files"}
+	}
+
+	cc, err := findCCompiler(*ccFlag)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := compileFiles(files, d)
+
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "comet-run-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(dir)
+
+	executable := filepath.Join(dir, filepath.Base(defaultOutput(files, "")))
+
+	err = compileNative(cc, c, executable)
+
+	if err != nil {
+		return err
+	}
+
+	return runNative(executable, programArgs)
+}
+
+func runCheck(args []string) error {
+	flags := newFlagSet("check")
+	d := dumps{}
+	d.register(flags)
+
+	files, err := parseFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = analyzeFiles(files, d)
+
+	return err
+}
+
+func runTokens(args []string) error {
+	flags := newFlagSet("tokens")
+	d := dumps{}
+	flags.BoolVar(&d.debug, "debug", false, "print debug output to stderr")
+
+	files, err := parseFlags(flags, args)
+
+	if err != nil {
+		return err
 	}
 
-	c, err := compiler.CompileC(&statement)
+	tokens, err := tokenizeFiles(files, d.options())
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	fmt.Println(c)
+	printTokens(tokens)
+
+	return nil
+}
+
+func runAst(args []string) error {
+	flags := newFlagSet("ast")
+	populated := flags.Bool("populated", false, "print the syntax tree after static analysis")
+	d := dumps{}
+	flags.BoolVar(&d.debug, "debug", false, "print debug output to stderr")
+
+	files, err := parseFlags(flags, args)
 
-	// Write to test file
-	// create file
-	f, err := os.Create("test/test.c")
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+
+	if *populated {
+		root, err := analyzeFiles(files, d)
+
+		if err != nil {
+			return err
+		}
+
+		parser.PrintAST(root, 0)
+
+		return nil
+	}
+
+	options := d.options()
+	tokens, err := tokenizeFiles(files, options)
+
+	if err != nil {
+		return err
+	}
+
+	root, err := parser.ParseTokens(tokens, options)
+
+	if err != nil {
+		return err
+	}
+
+	parser.PrintAST(root, 0)
+
+	return nil
+}
+
+func runEmitC(args []string) error {
+	flags := newFlagSet("emit-c")
+	output := flags.String("o", "", "output path (default: stdout)")
+	d := dumps{}
+	flags.BoolVar(&d.debug, "debug", false, "print debug output to stderr")
+
+	files, err := parseFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := compileFiles(files, d)
+
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fmt.Print(c)
+		return nil
 	}
-	// remember to close the file
-	defer f.Close()
 
-	f.WriteString(c)
+	return os.WriteFile(*output, []byte(c), 0644)
 }
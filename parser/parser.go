@@ -2,24 +2,31 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/yonedash/comet/analysis"
+	"github.com/yonedash/comet/diagnostics"
 	"github.com/yonedash/comet/lexer"
 )
 
 type ParseError struct {
-	message string
-	trace   *analysis.SourceTrace
+	diagnostic diagnostics.Diagnostic
 }
 
 func (e ParseError) Error() string {
-	return e.message
+	return e.diagnostic.Error()
+}
+
+func (e ParseError) Unwrap() error {
+	return e.diagnostic
 }
 
 type tokenParser struct {
-	tokens *[]lexer.Token
-	length int
-	index  int
+	options         analysis.Options
+	tokens          *[]lexer.Token
+	length          int
+	index           int
+	noStructLiteral bool // Name { } opens the scope of a condition instead
 }
 
 func (r tokenParser) at(i int) lexer.Token {
@@ -52,11 +59,31 @@ func (r tokenParser) isDone() bool {
 	return r.index >= r.length || r.at(r.index).Type == lexer.EOF
 }
 
-func ParseTokens(tokens []lexer.Token) (Statement, error) {
+// Span from the start token to the last token consumed, line feeds and semicolons ending a statement are left out
+func (r tokenParser) spanFrom(start lexer.Token) analysis.SourceTrace {
+	if start.Trace == nil {
+		return analysis.SourceTrace{}
+	}
+
+	i := r.index - 1
+
+	for i > 0 && (r.at(i).Type == lexer.LF || r.at(i).Type == lexer.Semicolon) {
+		i--
+	}
+
+	if last := r.at(i); last.Trace != nil {
+		return start.Trace.To(*last.Trace)
+	}
+
+	return *start.Trace
+}
+
+func ParseTokens(tokens []lexer.Token, options analysis.Options) (Statement, error) {
 	parser := tokenParser{
-		tokens: &tokens,
-		length: len(tokens),
-		index:  0,
+		options: options,
+		tokens:  &tokens,
+		length:  len(tokens),
+		index:   0,
 	}
 
 	children := []*Statement{}
@@ -66,24 +93,16 @@ func ParseTokens(tokens []lexer.Token) (Statement, error) {
 			break
 		}
 
-		current := parser.current()
-
 		statement, err := parseStatement(&parser)
 		if err != nil {
 			return Statement{}, err
 		}
 
-		skip, err := processStatement(current, &statement)
-
-		if err != nil {
-			return Statement{}, err
-		}
-
-		if skip {
+		if statement.Type < 0 {
 			continue
 		}
 
-		// fmt.Printf("%d %+v\n", parser.index, statement)
+		parser.options.Debugf("parser: %d %+v", parser.index, statement)
 
 		children = append(children, &statement)
 	}
@@ -93,22 +112,21 @@ func ParseTokens(tokens []lexer.Token) (Statement, error) {
 		Children: children,
 	}
 
-	return root, nil
-}
-
-func processStatement(start lexer.Token, statement *Statement) (bool, error) {
-	if statement.Type < 0 {
-		return true, nil
+	if len(tokens) > 0 {
+		root.Trace = parser.spanFrom(tokens[0])
 	}
 
-	statement.Trace = *start.Trace
-
-	return false, nil
+	return root, nil
 }
 
 func demandNewLineOrSemicolon(parser *tokenParser, statement Statement) (Statement, error) {
 	current := parser.current()
 
+	// Last statement of a scope on one line: { break }
+	if current.Type == lexer.CloseCurlyBracket {
+		return statement, nil
+	}
+
 	if current.Type != lexer.LF && current.Type != lexer.Semicolon && current.Type != lexer.EOF {
 		return Statement{}, parseError(current, "Expected new line or semicolon")
 	}
@@ -118,7 +136,22 @@ func demandNewLineOrSemicolon(parser *tokenParser, statement Statement) (Stateme
 	return statement, nil
 }
 
+// Parses the statement at the current token, its trace spans all of its tokens
 func parseStatement(parser *tokenParser) (Statement, error) {
+	start := parser.current()
+
+	statement, err := parseStatementOfToken(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	statement.Trace = parser.spanFrom(start)
+
+	return statement, nil
+}
+
+func parseStatementOfToken(parser *tokenParser) (Statement, error) {
 	current := parser.current()
 	switch current.Type {
 	case lexer.LF, lexer.Semicolon: // Ignore line feed / semicolon
@@ -128,10 +161,34 @@ func parseStatement(parser *tokenParser) (Statement, error) {
 		return parseScope(parser)
 	case lexer.Function:
 		return parseFunction(parser)
+	case lexer.Struct:
+		return parseStruct(parser)
+	case lexer.Enum:
+		return parseEnum(parser)
+	case lexer.Match:
+		return parseMatch(parser)
 	case lexer.Import:
 		return parseImport(parser)
 	case lexer.Var, lexer.Const:
 		return parseVariableDeclaration(parser)
+	case lexer.If:
+		return parseConditional(parser)
+	case lexer.While:
+		return parseWhile(parser)
+	case lexer.For:
+		return parseFor(parser)
+	case lexer.Return:
+		return parseReturn(parser)
+	case lexer.Break, lexer.Continue:
+		statementType := BreakStatement
+		if current.Type == lexer.Continue {
+			statementType = ContinueStatement
+		}
+
+		// Consume keyword
+		parser.consume()
+
+		return demandNewLineOrSemicolon(parser, Statement{Type: statementType})
 	case lexer.Identifier, lexer.OpenParenthesis:
 		if current.Type == lexer.Identifier && parser.after().Type == lexer.OpenParenthesis {
 			return parseFunctionCall(parser)
@@ -139,7 +196,29 @@ func parseStatement(parser *tokenParser) (Statement, error) {
 		return parseVariableAssign(parser)
 	}
 
-	return Statement{}, parseError(current, fmt.Sprintf("Unexpected token, statement expected (%d)", current.Type))
+	return Statement{}, parseError(current, "Unexpected token, statement expected")
+}
+
+// Conversion of one value to a builtin type: float64(x)
+func parseConversion(parser *tokenParser, aType ActualType) (Statement, error) {
+	current := parser.current()
+
+	call, err := parseFunctionCall(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	if len(call.Expressions) != 1 {
+		return Statement{}, parseError(current, fmt.Sprintf("Conversion to %s takes exactly one value", current.Value))
+	}
+
+	return Statement{
+		Type:  ConversionExpression,
+		Right: call.Expressions[0],
+		Types: []ActualType{aType},
+		Trace: *current.Trace,
+	}, nil
 }
 
 func parseFunctionCall(parser *tokenParser) (Statement, error) {
@@ -197,7 +276,127 @@ func parseExpression(parser *tokenParser) (Statement, error) {
 	/*expression := Statement{}
 
 	return expression, nil*/
-	return parseAdditiveExpression(parser)
+	return parseLogicalOrExpression(parser)
+}
+
+// Binary operators of each precedence level, from lowest to highest
+var (
+	logicalOrOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.LogicalOr: OrOperation,
+	}
+	logicalAndOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.LogicalAnd: AndOperation,
+	}
+	equalityOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.CompareEquals:    EqualsOperation,
+		lexer.CompareNotEquals: NotEqualsOperation,
+	}
+	comparisonOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.CompareSmaller:       SmallerOperation,
+		lexer.CompareSmallerEquals: SmallerEqualsOperation,
+		lexer.CompareBigger:        BiggerOperation,
+		lexer.CompareBiggerEquals:  BiggerEqualsOperation,
+	}
+	bitwiseOrOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.BitwiseOr: BitwiseOrOperation,
+	}
+	bitwiseXorOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.BitwiseXor: BitwiseXorOperation,
+	}
+	bitwiseAndOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.BitwiseAnd: BitwiseAndOperation,
+	}
+	shiftOperators = map[lexer.TokenType]BinaryOperation{
+		lexer.ShiftLeft:  ShiftLeftOperation,
+		lexer.ShiftRight: ShiftRightOperation,
+	}
+)
+
+var compoundAssignmentOperators = map[lexer.TokenType]BinaryOperation{
+	lexer.AdditionEquals:       AdditionOperation,
+	lexer.SubtractionEquals:    SubtractionOperation,
+	lexer.MultiplicationEquals: MultiplicationOperation,
+	lexer.DivisionEquals:       DivisionOperation,
+	lexer.ModulusEquals:        ModulusOperation,
+}
+
+var unaryOperators = map[lexer.TokenType]UnaryOperation{
+	lexer.LogicalNot:  NotOperation,
+	lexer.Subtraction: NegateOperation,
+	lexer.BitwiseNot:  BitwiseNotOperation,
+}
+
+// Parses left-associative binary expressions of operators, operands are parsed by next
+func parseBinaryLevel(parser *tokenParser, operators map[lexer.TokenType]BinaryOperation, next func(*tokenParser) (Statement, error)) (Statement, error) {
+	left, err := next(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	for {
+		if parser.isDone() {
+			break
+		}
+
+		operation, found := operators[parser.current().Type]
+
+		if !found {
+			break
+		}
+
+		parser.consume()
+
+		right, err := next(parser)
+
+		if err != nil {
+			return Statement{}, err
+		}
+
+		leftCopy := left
+
+		left = Statement{
+			Type:     BinaryExpression,
+			Left:     &leftCopy,
+			Right:    &right,
+			Operator: operation,
+			Trace:    leftCopy.Trace.To(right.Trace),
+		}
+	}
+
+	return left, nil
+}
+
+func parseLogicalOrExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, logicalOrOperators, parseLogicalAndExpression)
+}
+
+func parseLogicalAndExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, logicalAndOperators, parseEqualityExpression)
+}
+
+func parseEqualityExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, equalityOperators, parseComparisonExpression)
+}
+
+func parseComparisonExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, comparisonOperators, parseBitwiseOrExpression)
+}
+
+func parseBitwiseOrExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, bitwiseOrOperators, parseBitwiseXorExpression)
+}
+
+func parseBitwiseXorExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, bitwiseXorOperators, parseBitwiseAndExpression)
+}
+
+func parseBitwiseAndExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, bitwiseAndOperators, parseShiftExpression)
+}
+
+func parseShiftExpression(parser *tokenParser) (Statement, error) {
+	return parseBinaryLevel(parser, shiftOperators, parseAdditiveExpression)
 }
 
 func parseAdditiveExpression(parser *tokenParser) (Statement, error) {
@@ -242,6 +441,7 @@ func parseAdditiveExpression(parser *tokenParser) (Statement, error) {
 			Left:     leftPtrCopy,
 			Right:    rightPtrCopy,
 			Operator: operation,
+			Trace:    leftPtrCopy.Trace.To(rightPtrCopy.Trace),
 		}
 		left := mutableLeft
 		leftPtr = &left
@@ -252,7 +452,7 @@ func parseAdditiveExpression(parser *tokenParser) (Statement, error) {
 }
 
 func parseMultiplicativeExpression(parser *tokenParser) (Statement, error) {
-	left, err := parsePrimaryExpression(parser)
+	left, err := parseUnaryExpression(parser)
 	mutableLeft := left // Reassign this variable
 	leftPtr := &left
 
@@ -280,7 +480,7 @@ func parseMultiplicativeExpression(parser *tokenParser) (Statement, error) {
 			operation = ModulusOperation
 		}
 
-		right, err := parsePrimaryExpression(parser)
+		right, err := parseUnaryExpression(parser)
 		rightPtr := &right
 
 		if err != nil {
@@ -295,37 +495,191 @@ func parseMultiplicativeExpression(parser *tokenParser) (Statement, error) {
 			Left:     leftPtrCopy,
 			Right:    rightPtrCopy,
 			Operator: operation,
+			Trace:    leftPtrCopy.Trace.To(rightPtrCopy.Trace),
 		}
 		left := mutableLeft
 		leftPtr = &left
 	}
 
 	return mutableLeft, nil
-	//return parsePrimaryExpression(parser)
+	//return parseUnaryExpression(parser)
+}
+
+func parseUnaryExpression(parser *tokenParser) (Statement, error) {
+	token := parser.current()
+	operation, found := unaryOperators[token.Type]
+
+	if !found {
+		return parsePostfixExpression(parser)
+	}
+
+	// Consume operator
+	parser.consume()
+
+	operand, err := parseUnaryExpression(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	// Fold negative number literals
+	if operation == NegateOperation && operand.Type == NumberLiteral && operand.Value[0] != '-' {
+		operand.Value = "-" + operand.Value
+		operand.Trace = parser.spanFrom(token)
+		return operand, nil
+	}
+
+	return Statement{
+		Type:  UnaryExpression,
+		Right: &operand,
+		Unary: operation,
+		Trace: parser.spanFrom(token),
+	}, nil
+}
+
+// Parses field accesses and indices following a primary expression: a.b[i].c
+func parsePostfixExpression(parser *tokenParser) (Statement, error) {
+	start := parser.current()
+
+	expression, err := parsePrimaryExpression(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	for parser.current().Type == lexer.Dot || parser.current().Type == lexer.OpenSquareBracket {
+		if parser.current().Type == lexer.OpenSquareBracket {
+			parser.consume()
+
+			// Index is no condition
+			noStructLiteral := parser.noStructLiteral
+			parser.noStructLiteral = false
+
+			bounds, isRange, err := parseIndexOrRange(parser)
+
+			parser.noStructLiteral = noStructLiteral
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			array := expression
+
+			if isRange {
+				expression = Statement{
+					Type:        SliceExpression,
+					Left:        &array,
+					Expressions: bounds,
+					Trace:       parser.spanFrom(start),
+				}
+				continue
+			}
+
+			expression = Statement{
+				Type:  IndexExpression,
+				Left:  &array,
+				Right: bounds[0],
+				Trace: parser.spanFrom(start),
+			}
+			continue
+		}
+
+		// Consume .
+		parser.consume()
+		current := parser.current()
+
+		if current.Type != lexer.Identifier {
+			return Statement{}, parseError(current, "Expected field name after .")
+		}
+
+		object := expression
+		expression = Statement{
+			Type:  FieldExpression,
+			Left:  &object,
+			Value: current.Value,
+			Trace: start.Trace.To(*current.Trace),
+		}
+
+		// Payload of enum variant: Enum.Variant(values)
+		if parser.after().Type == lexer.OpenParenthesis {
+			call, err := parseFunctionCall(parser)
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			expression.Expressions = call.Expressions
+			expression.Trace = parser.spanFrom(start)
+			continue
+		}
+
+		parser.consume()
+	}
+
+	return expression, nil
 }
 
+// Parses the operand at the current token, its trace spans all of its tokens
 func parsePrimaryExpression(parser *tokenParser) (Statement, error) {
+	start := parser.current()
+
+	expression, err := parsePrimaryOfToken(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	expression.Trace = parser.spanFrom(start)
+
+	return expression, nil
+}
+
+func parsePrimaryOfToken(parser *tokenParser) (Statement, error) {
 	expression := Statement{}
 
 	token := parser.current()
 
 	switch token.Type {
 	case lexer.Identifier:
+		// Conversion to builtin type
+		if aType, _ := parseTypeName(token); aType.Id != Custom && parser.after().Type == lexer.OpenParenthesis {
+			return parseConversion(parser, aType)
+		}
+
 		// Function call
 		if parser.after().Type == lexer.OpenParenthesis {
 			return parseFunctionCall(parser)
 		}
 
+		// Struct literal: Name { field: value } or Name { }
+		if parser.after().Type == lexer.OpenCurlyBracket {
+			i := parser.index + 2
+			for parser.at(i).Type == lexer.LF {
+				i++
+			}
+
+			next := parser.at(i)
+
+			if (next.Type == lexer.Identifier && parser.at(i+1).Type == lexer.Colon) || (next.Type == lexer.CloseCurlyBracket && !parser.noStructLiteral) {
+				return parseStructLiteral(parser)
+			}
+		}
+
 		parser.consume()
 		return Statement{
 			Type:  IdentifierExpression,
 			Value: token.Value,
 		}, nil
 	case lexer.Number:
+		if _, found := NumberSuffixes[token.Suffix]; token.Suffix != "" && !found {
+			return Statement{}, parseError(token, fmt.Sprintf("Unknown number suffix %s", token.Suffix))
+		}
+
 		parser.consume()
 		return Statement{
 			Type:  NumberLiteral,
 			Value: token.Value,
+			Range: token.Suffix,
 		}, nil
 	case lexer.String:
 		parser.consume()
@@ -333,19 +687,35 @@ func parsePrimaryExpression(parser *tokenParser) (Statement, error) {
 			Type:  StringLiteral,
 			Value: token.Value,
 		}, nil
+	case lexer.Interpolation:
+		return parseInterpolation(parser)
+	case lexer.Character:
+		parser.consume()
+		return Statement{
+			Type:  CharacterLiteral,
+			Value: token.Value,
+		}, nil
 	case lexer.Boolean:
 		parser.consume()
 		return Statement{
 			Type:  BooleanLiteral,
 			Value: token.Value,
 		}, nil
+	case lexer.OpenSquareBracket:
+		return parseArrayLiteral(parser)
 	case lexer.OpenParenthesis:
 		parser.consume() // Consume opening
 
+		// Parenthesis end the condition, struct literals are allowed again
+		noStructLiteral := parser.noStructLiteral
+		parser.noStructLiteral = false
+
 		wrappedExpression, err := parseExpression(parser)
 
+		parser.noStructLiteral = noStructLiteral
+
 		if err != nil {
-			return Statement{}, nil
+			return Statement{}, err
 		}
 
 		current := parser.current()
@@ -362,62 +732,289 @@ func parsePrimaryExpression(parser *tokenParser) (Statement, error) {
 	return expression, parseError(token, "Unexpected token, expected expression")
 }
 
-func parseVariableAssign(parser *tokenParser) (Statement, error) {
-	current := parser.current()
+// Desugars "a${x}b" to "a" + string(x) + "b", the analyzer checks the conversion of each embedded expression
+func parseInterpolation(parser *tokenParser) (Statement, error) {
+	parts := []Statement{}
 
-	varIdentifiers := []*Statement{}
+	for {
+		current := parser.current()
+
+		if current.Value != "" {
+			parts = append(parts, Statement{
+				Type:  StringLiteral,
+				Value: current.Value,
+				Trace: *current.Trace,
+			})
+		}
 
-	if current.Type == lexer.OpenParenthesis {
-		// Consume parenthesis
 		parser.consume()
 
-		for {
-			// Check for possible end
-			if current.Type == lexer.CloseParenthesis && len(varIdentifiers) > 0 {
-				return Statement{}, parseError(current, "Unexpected token, expected identifier")
-			}
+		if current.Type == lexer.String {
+			break
+		}
 
-			// Get identifier
-			current = parser.current()
+		// Braces end the embedded expression like parenthesis
+		noStructLiteral := parser.noStructLiteral
+		parser.noStructLiteral = false
 
-			if current.Type != lexer.Identifier {
-				return Statement{}, parseError(current, "Unexpected token, expected identifier")
-			}
+		embedded, err := parseExpression(parser)
 
-			// Parse (function also consumes it)
-			identifier, err := parsePrimaryExpression(parser)
+		parser.noStructLiteral = noStructLiteral
 
-			if err != nil {
-				return Statement{}, err
-			}
+		if err != nil {
+			return Statement{}, err
+		}
 
-			// Add identifier
-			varIdentifiers = append(varIdentifiers, &identifier)
+		parts = append(parts, Statement{
+			Type:  ConversionExpression,
+			Right: &embedded,
+			Types: []ActualType{{Id: String}},
+			Trace: embedded.Trace,
+		})
 
-			current = parser.current()
+		if next := parser.current(); next.Type != lexer.Interpolation && next.Type != lexer.String {
+			return Statement{}, parseError(next, "Expected } after expression embedded in string")
+		}
+	}
 
-			// Check for possible end
-			if current.Type == lexer.CloseParenthesis {
-				parser.consume()
-				break
-			}
+	interpolation := parts[0]
 
-			// Check for next var
-			if current.Type == lexer.Comma {
-				parser.consume()
-				continue
-			}
+	for i := 1; i < len(parts); i++ {
+		left := interpolation
 
-			return Statement{}, parseError(current, "Unexpected token")
+		interpolation = Statement{
+			Type:     BinaryExpression,
+			Left:     &left,
+			Right:    &parts[i],
+			Operator: AdditionOperation,
+			Trace:    left.Trace.To(parts[i].Trace),
 		}
-	} else {
+	}
 
-		if current.Type != lexer.Identifier {
-			return Statement{}, parseError(current, "Expected identifier")
-		}
+	return interpolation, nil
+}
 
-		// Parse (function also consumes it)
-		identifier, err := parsePrimaryExpression(parser)
+// Parses index] or range start:end] after [, both bounds of the range are optional
+func parseIndexOrRange(parser *tokenParser) ([]*Statement, bool, error) {
+	bounds := []*Statement{nil, nil}
+	isRange := false
+
+	for i := range bounds {
+		current := parser.current()
+
+		if current.Type != lexer.Colon && current.Type != lexer.CloseSquareBracket {
+			bound, err := parseExpression(parser)
+
+			if err != nil {
+				return nil, false, err
+			}
+
+			bounds[i] = &bound
+		}
+
+		current = parser.current()
+
+		if current.Type == lexer.Colon && i == 0 {
+			parser.consume()
+			isRange = true
+			continue
+		}
+
+		if current.Type != lexer.CloseSquareBracket {
+			return nil, false, parseError(current, "Expected ] after index")
+		}
+
+		break
+	}
+
+	// Consume ]
+	parser.consume()
+
+	if !isRange && bounds[0] == nil {
+		return nil, false, parseError(parser.before(), "Expected index")
+	}
+
+	return bounds, isRange, nil
+}
+
+// Parses [value, ...], values may be spread over multiple lines
+func parseArrayLiteral(parser *tokenParser) (Statement, error) {
+	open := parser.consume()
+
+	// Values of the literal are no condition
+	noStructLiteral := parser.noStructLiteral
+	parser.noStructLiteral = false
+	defer func() { parser.noStructLiteral = noStructLiteral }()
+
+	values := []*Statement{}
+
+	for {
+		current := parser.current()
+
+		if current.Type == lexer.LF || (current.Type == lexer.Comma && len(values) > 0) {
+			parser.consume()
+			continue
+		}
+
+		if current.Type == lexer.CloseSquareBracket {
+			parser.consume()
+			break
+		}
+
+		value, err := parseExpression(parser)
+
+		if err != nil {
+			return Statement{}, err
+		}
+
+		values = append(values, &value)
+
+		current = parser.current()
+
+		if current.Type != lexer.Comma && current.Type != lexer.LF && current.Type != lexer.CloseSquareBracket {
+			return Statement{}, parseError(current, "Expected , or ] after value of array literal")
+		}
+	}
+
+	if len(values) == 0 {
+		return Statement{}, parseError(open, "Array literal needs at least one value")
+	}
+
+	return Statement{
+		Type:        ArrayLiteral,
+		Expressions: values,
+		Trace:       *open.Trace,
+	}, nil
+}
+
+// Parses Name { field: value, ... }, fields are separated by comma or new line
+func parseStructLiteral(parser *tokenParser) (Statement, error) {
+	name := parser.consume()
+
+	// Consume {
+	parser.consume()
+
+	// Fields of the literal are no condition
+	noStructLiteral := parser.noStructLiteral
+	parser.noStructLiteral = false
+	defer func() { parser.noStructLiteral = noStructLiteral }()
+
+	fieldNames := []string{}
+	fieldValues := []*Statement{}
+
+	for {
+		current := parser.current()
+
+		if current.Type == lexer.LF || current.Type == lexer.Comma {
+			parser.consume()
+			continue
+		}
+
+		if current.Type == lexer.CloseCurlyBracket {
+			parser.consume()
+			break
+		}
+
+		if current.Type != lexer.Identifier || parser.after().Type != lexer.Colon {
+			return Statement{}, parseError(current, "Expected field: value in struct literal")
+		}
+
+		// Consume name and colon
+		parser.consume()
+		parser.consume()
+
+		value, err := parseExpression(parser)
+
+		if err != nil {
+			return Statement{}, err
+		}
+
+		fieldNames = append(fieldNames, current.Value)
+		fieldValues = append(fieldValues, &value)
+
+		current = parser.current()
+
+		if current.Type != lexer.Comma && current.Type != lexer.LF && current.Type != lexer.CloseCurlyBracket {
+			return Statement{}, parseError(current, "Expected , or } after field of struct literal")
+		}
+	}
+
+	return Statement{
+		Type:        StructLiteral,
+		Value:       name.Value,
+		ArgNames:    fieldNames,
+		Expressions: fieldValues,
+		Trace:       *name.Trace,
+	}, nil
+}
+
+func parseVariableAssign(parser *tokenParser) (Statement, error) {
+	statement, err := parseAssignment(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	return demandNewLineOrSemicolon(parser, statement)
+}
+
+// Parses a variable assignment without the following new line or semicolon
+func parseAssignment(parser *tokenParser) (Statement, error) {
+	current := parser.current()
+
+	varIdentifiers := []*Statement{}
+
+	if current.Type == lexer.OpenParenthesis {
+		// Consume parenthesis
+		parser.consume()
+
+		for {
+			// Check for possible end
+			if current.Type == lexer.CloseParenthesis && len(varIdentifiers) > 0 {
+				return Statement{}, parseError(current, "Unexpected token, expected identifier")
+			}
+
+			// Get identifier
+			current = parser.current()
+
+			if current.Type != lexer.Identifier {
+				return Statement{}, parseError(current, "Unexpected token, expected identifier")
+			}
+
+			// Parse (function also consumes it)
+			identifier, err := parsePostfixExpression(parser)
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			// Add identifier
+			varIdentifiers = append(varIdentifiers, &identifier)
+
+			current = parser.current()
+
+			// Check for possible end
+			if current.Type == lexer.CloseParenthesis {
+				parser.consume()
+				break
+			}
+
+			// Check for next var
+			if current.Type == lexer.Comma {
+				parser.consume()
+				continue
+			}
+
+			return Statement{}, parseError(current, "Unexpected token")
+		}
+	} else {
+
+		if current.Type != lexer.Identifier {
+			return Statement{}, parseError(current, "Expected identifier")
+		}
+
+		// Parse (function also consumes it)
+		identifier, err := parsePostfixExpression(parser)
 
 		if err != nil {
 			return Statement{}, err
@@ -430,9 +1027,37 @@ func parseVariableAssign(parser *tokenParser) (Statement, error) {
 
 	current = parser.current()
 
-	// TODO add += -= etc here
+	// ++ and -- add or subtract 1 of every variable
+	if current.Type == lexer.Increment || current.Type == lexer.Decrement {
+		operation := AdditionOperation
+		if current.Type == lexer.Decrement {
+			operation = SubtractionOperation
+		}
+
+		// Consume operator
+		parser.consume()
 
-	if current.Type == lexer.Equals {
+		varExpressions := []*Statement{}
+		for range varIdentifiers {
+			varExpressions = append(varExpressions, &Statement{
+				Type:  NumberLiteral,
+				Value: "1",
+				Trace: *current.Trace,
+			})
+		}
+
+		return Statement{
+			Type:        VariableAssignment,
+			Identifiers: varIdentifiers,
+			Expressions: varExpressions,
+			Operator:    operation,
+			Compound:    true,
+		}, nil
+	}
+
+	operation, compound := compoundAssignmentOperators[current.Type]
+
+	if current.Type == lexer.Equals || compound {
 
 		// Consume equals
 		parser.consume()
@@ -493,11 +1118,13 @@ func parseVariableAssign(parser *tokenParser) (Statement, error) {
 			return Statement{}, parseError(current, "Identifier and expression count mismatch")
 		}
 
-		return demandNewLineOrSemicolon(parser, Statement{
+		return Statement{
 			Type:        VariableAssignment,
 			Identifiers: varIdentifiers,
 			Expressions: varExpressions,
-		})
+			Operator:    operation,
+			Compound:    compound,
+		}, nil
 	}
 
 	return Statement{}, parseError(current, "Unknown operation on variable")
@@ -505,6 +1132,7 @@ func parseVariableAssign(parser *tokenParser) (Statement, error) {
 
 func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 	current := parser.current()
+	doc := current.Doc
 
 	isConstant := current.Type == lexer.Const
 
@@ -576,9 +1204,6 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 
 	current = parser.current()
 
-	// Check if type is already assigned
-	selfAssignedType := ActualType{}
-
 	varTypes := []ActualType{}
 
 	if current.Type == lexer.Colon {
@@ -594,7 +1219,7 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 				current = parser.current()
 
 				// Get type
-				parsedType, err := parseType(current)
+				parsedType, err := parseType(parser)
 
 				if err != nil {
 					return Statement{}, err
@@ -610,9 +1235,6 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 
 				varTypes = append(varTypes, parsedType)
 
-				// Consume type
-				parser.consume()
-
 				current = parser.current()
 
 				// Check for possible end
@@ -639,7 +1261,7 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 				return Statement{}, parseError(current, "Expected type for implicit variable declaration")
 			}
 
-			parsedType, err := parseType(current)
+			parsedType, err := parseType(parser)
 
 			if err != nil {
 				return Statement{}, err
@@ -650,9 +1272,6 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 			}
 
 			varTypes = append(varTypes, parsedType)
-
-			// Consume type
-			parser.consume()
 		}
 	} else {
 		len := len(varIdentifiers)
@@ -704,10 +1323,6 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 				return Statement{}, parseError(current, "Cannot assign multiple expressions to a single variable")
 			}
 		} else {
-			if len(varIdentifiers) > 1 {
-				return Statement{}, parseError(current, "Cannot assign one expression to multiple variables")
-			}
-
 			// Get expression
 			expression, err := parseExpression(parser)
 
@@ -715,6 +1330,11 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 				return Statement{}, err
 			}
 
+			// Multiple values can only be returned by a function
+			if len(varIdentifiers) > 1 && expression.Type != FunctionExpression {
+				return Statement{}, parseError(current, "Cannot assign one expression to multiple variables")
+			}
+
 			varExpressions = append(varExpressions, &expression)
 		}
 	}
@@ -722,11 +1342,13 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 	// Update current
 	current = parser.current()
 
-	if selfAssignedType.Id == Void && len(varExpressions) == 0 {
+	destructure := len(varIdentifiers) > 1 && len(varExpressions) == 1
+
+	if varTypes[0].Id == Void && len(varExpressions) == 0 {
 		return Statement{}, parseError(current, "Implicit declaration of type needed when not assigning a value")
 	}
 
-	if len(varIdentifiers) != len(varExpressions) && len(varExpressions) > 0 {
+	if len(varIdentifiers) != len(varExpressions) && len(varExpressions) > 0 && !destructure {
 		return Statement{}, parseError(current, "Identifier and expression count mismatch")
 	}
 
@@ -735,7 +1357,7 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 	}
 
 	if len(varTypes) == 1 {
-		count := len(varExpressions) - 1
+		count := len(varIdentifiers) - 1
 		for i := 0; i < count; i++ {
 			varTypes = append(varTypes, varTypes[0])
 		}
@@ -747,6 +1369,8 @@ func parseVariableDeclaration(parser *tokenParser) (Statement, error) {
 		Expressions: varExpressions,
 		Types:       varTypes,
 		Constant:    isConstant,
+		Destructure: destructure,
+		Doc:         doc,
 	})
 }
 
@@ -758,6 +1382,8 @@ func getOrMultiGetExpr(parser *tokenParser) ([]Statement, error) {
 
 	// Check for multiple return values
 	if current.Type == lexer.OpenParenthesis {
+		start := parser.index
+
 		// Consume (
 		parser.consume()
 
@@ -797,6 +1423,19 @@ func getOrMultiGetExpr(parser *tokenParser) ([]Statement, error) {
 			return []Statement{}, parseError(current, "Unexpected token in ()")
 		}
 
+		// Single value in parenthesis may continue as expression: (a + b) * c
+		if len(result) == 1 {
+			parser.index = start
+
+			parsed, err := parseExpression(parser)
+
+			if err != nil {
+				return []Statement{}, err
+			}
+
+			result[0] = parsed
+		}
+
 	} else {
 		// Check for single return value
 		parsed, err := parseExpression(parser)
@@ -811,6 +1450,36 @@ func getOrMultiGetExpr(parser *tokenParser) ([]Statement, error) {
 	return result, nil
 }
 
+func parseReturn(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	current := parser.current()
+
+	switch current.Type {
+	case lexer.LF, lexer.Semicolon, lexer.EOF, lexer.CloseCurlyBracket:
+		return demandNewLineOrSemicolon(parser, Statement{Type: ReturnStatement})
+	}
+
+	values, err := getOrMultiGetExpr(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	expressions := []*Statement{}
+	for i := range values {
+		expressions = append(expressions, &values[i])
+	}
+
+	statement := Statement{
+		Type:        ReturnStatement,
+		Expressions: expressions,
+	}
+
+	return demandNewLineOrSemicolon(parser, statement)
+}
+
 func parseImport(parser *tokenParser) (Statement, error) {
 	// Consume keyword
 	token := parser.consume()
@@ -845,6 +1514,8 @@ func parseImport(parser *tokenParser) (Statement, error) {
 }
 
 func parseFunction(parser *tokenParser) (Statement, error) {
+	doc := parser.current().Doc
+
 	// Consume keyword
 	parser.consume()
 
@@ -891,14 +1562,12 @@ func parseFunction(parser *tokenParser) (Statement, error) {
 			break
 		}
 
-		argType, err := parseType(current)
+		argType, err := parseType(parser)
 
 		if err != nil {
 			return Statement{}, err
 		}
 
-		// Consume type
-		parser.consume()
 		current = parser.current()
 
 		// Check whether type is variadic
@@ -974,14 +1643,12 @@ func parseFunction(parser *tokenParser) (Statement, error) {
 					return Statement{}, parseError(current, "Unexpected token, expected type")
 				}
 
-				returnType, err := parseType(current)
+				returnType, err := parseType(parser)
 
 				if err != nil {
 					return Statement{}, err
 				}
 
-				// Consume type
-				parser.consume()
 				returnTypes = append(returnTypes, returnType)
 
 				current = parser.current()
@@ -1004,16 +1671,13 @@ func parseFunction(parser *tokenParser) (Statement, error) {
 
 		} else {
 			// Check for single return value
-			returnType, err := parseType(current)
+			returnType, err := parseType(parser)
 
 			if err != nil {
 				return Statement{}, err
 			}
 
 			returnTypes = append(returnTypes, returnType)
-
-			// Consume type
-			parser.consume()
 		}
 
 	} else {
@@ -1052,41 +1716,649 @@ func parseFunction(parser *tokenParser) (Statement, error) {
 		Types:    returnTypes,
 		RunScope: &scope,
 		Native:   isNative,
+		Doc:      doc,
 	}, nil
 }
 
-func parseType(token lexer.Token) (ActualType, error) {
-	if token.Type != lexer.Identifier {
-		return ActualType{}, parseError(token, "Expected type")
+// Parses struct Name { field: type ... }, fields are separated by comma, semicolon or new line
+func parseStruct(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	current := parser.current()
+
+	if current.Type != lexer.Identifier {
+		return Statement{}, parseError(current, "Struct has invalid identifier")
 	}
 
-	switch token.Value {
-	case "void":
-		return ActualType{Id: Void}, nil
-	case "int8", "char":
-		return ActualType{Id: Int8}, nil
-	case "int16":
-		return ActualType{Id: Int16}, nil
-	case "int32", "int":
-		return ActualType{Id: Int32}, nil
-	case "int64":
-		return ActualType{Id: Int64}, nil
-	case "uint8":
-		return ActualType{Id: UnsignedInt8}, nil
-	case "uint16":
-		return ActualType{Id: UnsignedInt16}, nil
-	case "uint32":
-		return ActualType{Id: UnsignedInt32}, nil
-	case "uint64":
-		return ActualType{Id: UnsignedInt64}, nil
-	case "float32", "float":
-		return ActualType{Id: Float32}, nil
-	case "float64", "double":
-		return ActualType{Id: Float64}, nil
-	case "complex64":
-		return ActualType{Id: Complex64}, nil
-	case "complex128":
-		return ActualType{Id: Complex128}, nil
+	if aType, _ := parseTypeName(current); aType.Id != Custom {
+		return Statement{}, parseError(current, fmt.Sprintf("Cannot declare struct with the name of type %s", current.Value))
+	}
+
+	name := parser.consume().Value
+	current = parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Struct is missing {")
+	}
+
+	// Consume {
+	parser.consume()
+
+	fieldNames := []string{}
+	fieldTypes := []ActualType{}
+
+	for {
+		current = parser.current()
+
+		if current.Type == lexer.LF || current.Type == lexer.Semicolon || current.Type == lexer.Comma {
+			parser.consume()
+			continue
+		}
+
+		if current.Type == lexer.CloseCurlyBracket {
+			parser.consume()
+			break
+		}
+
+		if current.Type != lexer.Identifier {
+			return Statement{}, parseError(current, "Expected field name")
+		}
+
+		fieldName := parser.consume().Value
+		current = parser.current()
+
+		if current.Type != lexer.Colon {
+			return Statement{}, parseError(current, "Expected : after field name")
+		}
+
+		// Consume colon
+		parser.consume()
+		current = parser.current()
+
+		fieldType, err := parseType(parser)
+
+		if err != nil {
+			return Statement{}, err
+		}
+
+		if fieldType.Id == Void {
+			return Statement{}, parseError(current, "Cannot declare field as void")
+		}
+
+		fieldNames = append(fieldNames, fieldName)
+		fieldTypes = append(fieldTypes, fieldType)
+	}
+
+	return Statement{
+		Type:     StructDeclaration,
+		Value:    name,
+		ArgNames: fieldNames,
+		ArgTypes: fieldTypes,
+	}, nil
+}
+
+// Parses enum Name { Variant, Variant(type, ...) ... }, variants are separated by comma, semicolon or new line
+func parseEnum(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	current := parser.current()
+
+	if current.Type != lexer.Identifier {
+		return Statement{}, parseError(current, "Enum has invalid identifier")
+	}
+
+	if aType, _ := parseTypeName(current); aType.Id != Custom {
+		return Statement{}, parseError(current, fmt.Sprintf("Cannot declare enum with the name of type %s", current.Value))
+	}
+
+	name := parser.consume().Value
+	current = parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Enum is missing {")
+	}
+
+	// Consume {
+	parser.consume()
+
+	variants := []*Statement{}
+
+	for {
+		current = parser.current()
+
+		if current.Type == lexer.LF || current.Type == lexer.Semicolon || current.Type == lexer.Comma {
+			parser.consume()
+			continue
+		}
+
+		if current.Type == lexer.CloseCurlyBracket {
+			parser.consume()
+			break
+		}
+
+		if current.Type != lexer.Identifier {
+			return Statement{}, parseError(current, "Expected variant name")
+		}
+
+		start := current
+
+		variant := Statement{
+			Type:     IdentifierExpression,
+			Value:    parser.consume().Value,
+			ArgTypes: []ActualType{},
+		}
+
+		// Payload types
+		if parser.current().Type == lexer.OpenParenthesis {
+			parser.consume()
+
+			for {
+				current = parser.current()
+
+				payloadType, err := parseType(parser)
+
+				if err != nil {
+					return Statement{}, err
+				}
+
+				if payloadType.Id == Void {
+					return Statement{}, parseError(current, "Cannot declare payload as void")
+				}
+
+				variant.ArgTypes = append(variant.ArgTypes, payloadType)
+
+				current = parser.current()
+
+				if current.Type == lexer.CloseParenthesis {
+					parser.consume()
+					break
+				}
+
+				if current.Type == lexer.Comma {
+					parser.consume()
+					continue
+				}
+
+				return Statement{}, parseError(current, "Unexpected token in payload of variant, expecting ) or ,")
+			}
+		}
+
+		variant.Trace = parser.spanFrom(start)
+		variants = append(variants, &variant)
+	}
+
+	if len(variants) == 0 {
+		return Statement{}, parseError(current, fmt.Sprintf("Enum %s needs at least one variant", name))
+	}
+
+	return Statement{
+		Type:     EnumDeclaration,
+		Value:    name,
+		Children: variants,
+	}, nil
+}
+
+// Parses match value { Variant(a, b) -> { } ... else -> { } }
+func parseMatch(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	value, err := parseCondition(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	current := parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Expected { for match")
+	}
+
+	// Consume {
+	parser.consume()
+
+	arms := []*Statement{}
+
+	for {
+		current = parser.current()
+
+		if current.Type == lexer.LF || current.Type == lexer.Semicolon || current.Type == lexer.Comma {
+			parser.consume()
+			continue
+		}
+
+		if current.Type == lexer.CloseCurlyBracket {
+			parser.consume()
+			break
+		}
+
+		start := current
+
+		arm := Statement{
+			Type:     MatchArm,
+			ArgNames: []string{},
+		}
+
+		if current.Type == lexer.Identifier {
+			arm.Value = parser.consume().Value
+
+			// Names bound to the payload
+			if parser.current().Type == lexer.OpenParenthesis {
+				parser.consume()
+
+				for {
+					current = parser.current()
+
+					if current.Type != lexer.Identifier {
+						return Statement{}, parseError(current, "Expected name for payload of variant")
+					}
+
+					arm.ArgNames = append(arm.ArgNames, parser.consume().Value)
+					current = parser.current()
+
+					if current.Type == lexer.CloseParenthesis {
+						parser.consume()
+						break
+					}
+
+					if current.Type == lexer.Comma {
+						parser.consume()
+						continue
+					}
+
+					return Statement{}, parseError(current, "Unexpected token in payload of variant, expecting ) or ,")
+				}
+			}
+		} else if current.Type != lexer.Else {
+			return Statement{}, parseError(current, "Expected variant or else in match")
+		} else {
+			parser.consume()
+		}
+
+		current = parser.current()
+
+		if current.Type != lexer.ArrowRight {
+			return Statement{}, parseError(current, "Expected -> after variant")
+		}
+
+		// Consume ->
+		parser.consume()
+
+		scope, err := parseScope(parser)
+
+		if err != nil {
+			return Statement{}, err
+		}
+
+		arm.RunScope = &scope
+		arm.Trace = parser.spanFrom(start)
+		arms = append(arms, &arm)
+	}
+
+	return Statement{
+		Type:      MatchStatement,
+		Condition: &value,
+		Children:  arms,
+	}, nil
+}
+
+// Parses the condition of if and while, { opens their scope
+func parseCondition(parser *tokenParser) (Statement, error) {
+	parser.noStructLiteral = true
+	condition, err := parseExpression(parser)
+	parser.noStructLiteral = false
+
+	return condition, err
+}
+
+func parseConditional(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	condition, err := parseCondition(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	current := parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Expected new scope for if")
+	}
+
+	scope, err := parseScope(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	conditional := Statement{
+		Type:      ConditionalStatement,
+		Condition: &condition,
+		RunScope:  &scope,
+	}
+
+	current = parser.current()
+
+	if current.Type != lexer.Else {
+		return conditional, nil
+	}
+
+	// Consume else
+	parser.consume()
+	current = parser.current()
+
+	var elseScope Statement
+
+	if current.Type == lexer.If {
+		elseScope, err = parseConditional(parser)
+	} else if current.Type == lexer.OpenCurlyBracket {
+		elseScope, err = parseScope(parser)
+	} else {
+		return Statement{}, parseError(current, "Expected new scope or if for else")
+	}
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	elseScope.Trace = parser.spanFrom(current)
+	conditional.ElseScope = &elseScope
+
+	return conditional, nil
+}
+
+func parseWhile(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	condition, err := parseCondition(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	current := parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Expected new scope for while")
+	}
+
+	scope, err := parseScope(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	return Statement{
+		Type:      LoopStatement,
+		Condition: &condition,
+		RunScope:  &scope,
+	}, nil
+}
+
+// Parses for (initializer; condition; step) { } or for { }, every part of () is optional
+func parseFor(parser *tokenParser) (Statement, error) {
+	// Consume keyword
+	parser.consume()
+
+	loop := Statement{Type: LoopStatement}
+	current := parser.current()
+
+	// Code points of a string: for (var c in text) { }
+	if current.Type == lexer.OpenParenthesis && parser.at(parser.index+1).Type == lexer.Var && parser.at(parser.index+3).Value == "in" {
+		return parseForIn(parser)
+	}
+
+	if current.Type == lexer.OpenParenthesis {
+		// Consume (
+		parser.consume()
+		current = parser.current()
+
+		// Initializer, consumes ;
+		if current.Type == lexer.Semicolon {
+			parser.consume()
+		} else {
+			var initializer Statement
+			var err error
+
+			if current.Type == lexer.Var || current.Type == lexer.Const {
+				initializer, err = parseVariableDeclaration(parser)
+			} else {
+				initializer, err = parseVariableAssign(parser)
+			}
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			if parser.before().Type != lexer.Semicolon {
+				return Statement{}, parseError(parser.before(), "Expected ; after initializer of for")
+			}
+
+			initializer.Trace = parser.spanFrom(current)
+			loop.Initializer = &initializer
+		}
+
+		// Condition
+		current = parser.current()
+
+		if current.Type != lexer.Semicolon {
+			condition, err := parseExpression(parser)
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			loop.Condition = &condition
+			current = parser.current()
+
+			if current.Type != lexer.Semicolon {
+				return Statement{}, parseError(current, "Expected ; after condition of for")
+			}
+		}
+
+		// Consume ;
+		parser.consume()
+		current = parser.current()
+
+		// Step
+		if current.Type != lexer.CloseParenthesis {
+			step, err := parseAssignment(parser)
+
+			if err != nil {
+				return Statement{}, err
+			}
+
+			step.Trace = parser.spanFrom(current)
+			loop.Step = &step
+			current = parser.current()
+
+			if current.Type != lexer.CloseParenthesis {
+				return Statement{}, parseError(current, "Expected ) after step of for")
+			}
+		}
+
+		// Consume )
+		parser.consume()
+		current = parser.current()
+	}
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Expected new scope for for")
+	}
+
+	scope, err := parseScope(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	loop.RunScope = &scope
+
+	return loop, nil
+}
+
+// Parses for (var c in text) { } after for, c is declared as rune
+func parseForIn(parser *tokenParser) (Statement, error) {
+	// Consume ( and var
+	parser.consume()
+	parser.consume()
+
+	identifier := parser.current()
+
+	if identifier.Type != lexer.Identifier {
+		return Statement{}, parseError(identifier, "Expected variable name after var")
+	}
+
+	// Consume identifier and in
+	parser.consume()
+	parser.consume()
+
+	iterated, err := parseExpression(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	current := parser.current()
+
+	if current.Type != lexer.CloseParenthesis {
+		return Statement{}, parseError(current, "Expected ) after value iterated by for")
+	}
+
+	// Consume )
+	parser.consume()
+	current = parser.current()
+
+	if current.Type != lexer.OpenCurlyBracket {
+		return Statement{}, parseError(current, "Expected new scope for for")
+	}
+
+	scope, err := parseScope(parser)
+
+	if err != nil {
+		return Statement{}, err
+	}
+
+	return Statement{
+		Type: LoopStatement,
+		Initializer: &Statement{
+			Type: VariableDeclaration,
+			Identifiers: []*Statement{{
+				Type:  IdentifierExpression,
+				Value: identifier.Value,
+				Trace: *identifier.Trace,
+			}},
+			Types: []ActualType{{Id: Rune}},
+			Trace: *identifier.Trace,
+		},
+		Right:    &iterated,
+		RunScope: &scope,
+	}, nil
+}
+
+// Parses type name followed by array sizes: int32[4][5]
+func parseType(parser *tokenParser) (ActualType, error) {
+	aType, err := parseTypeName(parser.current())
+
+	if err != nil {
+		return ActualType{}, err
+	}
+
+	// Consume type name
+	parser.consume()
+
+	for parser.current().Type == lexer.OpenSquareBracket {
+		// Consume [
+		parser.consume()
+		current := parser.current()
+
+		// Slice of elements: int32[]
+		if current.Type == lexer.CloseSquareBracket {
+			if aType.Id == Void {
+				return ActualType{}, parseError(current, "Cannot declare slice of void")
+			}
+
+			if len(aType.ArraySizes) > 0 || aType.Id == Slice {
+				return ActualType{}, parseError(current, "Slices can only hold single values")
+			}
+
+			// Consume ]
+			parser.consume()
+
+			element := aType
+			aType = ActualType{Id: Slice, ElementType: &element}
+			continue
+		}
+
+		if aType.Id == Slice {
+			return ActualType{}, parseError(current, "Cannot declare array of slices")
+		}
+
+		size, err := strconv.Atoi(current.Value)
+
+		if current.Type != lexer.Number || current.Suffix != "" || err != nil || size <= 0 {
+			return ActualType{}, parseError(current, "Expected positive integer as array size")
+		}
+
+		parser.consume()
+
+		if parser.current().Type != lexer.CloseSquareBracket {
+			return ActualType{}, parseError(parser.current(), "Expected ] after array size")
+		}
+
+		// Consume ]
+		parser.consume()
+
+		aType.ArraySizes = append(aType.ArraySizes, size)
+	}
+
+	if aType.Id == Void && len(aType.ArraySizes) > 0 {
+		return ActualType{}, parseError(parser.before(), "Cannot declare array of void")
+	}
+
+	return aType, nil
+}
+
+func parseTypeName(token lexer.Token) (ActualType, error) {
+	if token.Type != lexer.Identifier {
+		return ActualType{}, parseError(token, "Expected type")
+	}
+
+	switch token.Value {
+	case "void":
+		return ActualType{Id: Void}, nil
+	case "int8":
+		return ActualType{Id: Int8}, nil
+	case "rune", "char":
+		return ActualType{Id: Rune}, nil
+	case "int16":
+		return ActualType{Id: Int16}, nil
+	case "int32", "int":
+		return ActualType{Id: Int32}, nil
+	case "int64":
+		return ActualType{Id: Int64}, nil
+	case "uint8":
+		return ActualType{Id: UnsignedInt8}, nil
+	case "uint16":
+		return ActualType{Id: UnsignedInt16}, nil
+	case "uint32":
+		return ActualType{Id: UnsignedInt32}, nil
+	case "uint64":
+		return ActualType{Id: UnsignedInt64}, nil
+	case "float32", "float":
+		return ActualType{Id: Float32}, nil
+	case "float64", "double":
+		return ActualType{Id: Float64}, nil
+	case "complex64":
+		return ActualType{Id: Complex64}, nil
+	case "complex128":
+		return ActualType{Id: Complex128}, nil
 	case "bool":
 		return ActualType{Id: Bool}, nil
 	case "string":
@@ -1097,7 +2369,8 @@ func parseType(token lexer.Token) (ActualType, error) {
 }
 
 func parseScope(parser *tokenParser) (Statement, error) {
-	current := parser.current()
+	open := parser.current()
+	current := open
 
 	if current.Type != lexer.OpenCurlyBracket {
 		return Statement{}, parseError(current, "Scope needs to be opened with {")
@@ -1117,19 +2390,17 @@ func parseScope(parser *tokenParser) (Statement, error) {
 			break
 		}
 
-		statement, err := parseStatement(parser)
-
-		if err != nil {
-			return Statement{}, err
+		if current.Type == lexer.EOF {
+			break
 		}
 
-		skip, err := processStatement(current, &statement)
+		statement, err := parseStatement(parser)
 
 		if err != nil {
 			return Statement{}, err
 		}
 
-		if skip {
+		if statement.Type < 0 {
 			continue
 		}
 
@@ -1137,27 +2408,39 @@ func parseScope(parser *tokenParser) (Statement, error) {
 	}
 
 	if !closed {
-		return Statement{}, parseError(current, "Scope needs to be closed with }")
+		diagnostic := diagnosticOf(current, "Scope needs to be closed with }").WithSecondary(*open.Trace, "scope opened here")
+		return Statement{}, ParseError{diagnostic: diagnostic}
 	}
 
 	scope := Statement{
 		Type:     ScopeDeclaration,
 		Children: children,
+		Trace:    parser.spanFrom(open),
 	}
 
 	return scope, nil
 }
 
 func parseError(token lexer.Token, message string) error {
-	// Return error if unknown character is in source
-	trace := token.Trace
+	return ParseError{diagnostic: diagnosticOf(token, message)}
+}
+
+// Error at the token, line feeds and the end of file are named as they are invisible in the source
+func diagnosticOf(token lexer.Token, message string) diagnostics.Diagnostic {
+	trace := analysis.SourceTrace{}
 
-	if trace == nil {
-		return ParseError{message: "No trace found for error"}
+	if token.Trace != nil {
+		trace = *token.Trace
 	}
 
-	row, col := trace.Row, trace.Column
-	msg := fmt.Sprintf("%s @ %d:%d >> %+v", message, row, col, token)
+	diagnostic := diagnostics.New(diagnostics.Error, diagnostics.CodeSyntax, trace, message)
+
+	switch token.Type {
+	case lexer.LF:
+		return diagnostic.WithLabel("found end of line")
+	case lexer.EOF:
+		return diagnostic.WithLabel("found end of file")
+	}
 
-	return ParseError{message: msg, trace: trace}
+	return diagnostic
 }
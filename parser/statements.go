@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/yonedash/comet/analysis"
 )
@@ -13,15 +14,32 @@ const (
 	NullLiteral
 	NumberLiteral
 	StringLiteral
+	CharacterLiteral
 	BooleanLiteral
 	IdentifierExpression
 	BinaryExpression
+	UnaryExpression
+	ConversionExpression
+	FieldExpression
+	IndexExpression
+	SliceExpression
+	StructLiteral
+	ArrayLiteral
 	FunctionExpression
 	FunctionDeclaration
 	VariableDeclaration
+	StructDeclaration
+	EnumDeclaration
 	ScopeDeclaration
 	VariableAssignment
 	ImportStatement
+	ConditionalStatement
+	LoopStatement
+	BreakStatement
+	ContinueStatement
+	ReturnStatement
+	MatchStatement
+	MatchArm
 	// for context builder
 	MemoryDeAllocation
 )
@@ -34,6 +52,27 @@ const (
 	MultiplicationOperation
 	DivisionOperation
 	ModulusOperation
+	EqualsOperation
+	NotEqualsOperation
+	SmallerOperation
+	SmallerEqualsOperation
+	BiggerOperation
+	BiggerEqualsOperation
+	AndOperation
+	OrOperation
+	BitwiseAndOperation
+	BitwiseOrOperation
+	BitwiseXorOperation
+	ShiftLeftOperation
+	ShiftRightOperation
+)
+
+type UnaryOperation int
+
+const (
+	NotOperation UnaryOperation = iota
+	NegateOperation
+	BitwiseNotOperation
 )
 
 type TypeId int
@@ -42,17 +81,64 @@ type ActualType struct {
 	Id                       TypeId
 	CustomName               string
 	ArraySizes               []int
+	ElementType              *ActualType // Slice
 	Variadic                 bool
 	SkipValidateVariadicType bool
 	// Parent *ActualType // for something like: typedef number int32
 }
 
+// Type as written in source: int32, string[], Point[4]
+func (t ActualType) String() string {
+	name := typeNames[t.Id]
+
+	if t.Id == Custom {
+		name = t.CustomName
+	}
+
+	if t.Id == Slice && t.ElementType != nil {
+		name = t.ElementType.String() + "[]"
+	}
+
+	for _, size := range t.ArraySizes {
+		name += fmt.Sprintf("[%d]", size)
+	}
+
+	if t.SkipValidateVariadicType {
+		name += "..?"
+	} else if t.Variadic {
+		name += "..."
+	}
+
+	return name
+}
+
+// Checks if both types are the same, custom types are compared by name
+func (t ActualType) Equals(other ActualType) bool {
+	if t.Id != other.Id || t.CustomName != other.CustomName || len(t.ArraySizes) != len(other.ArraySizes) {
+		return false
+	}
+
+	for i, size := range t.ArraySizes {
+		if other.ArraySizes[i] != size {
+			return false
+		}
+	}
+
+	if t.Id == Slice {
+		return t.ElementType.Equals(*other.ElementType)
+	}
+
+	return true
+}
+
 const (
 	Void TypeId = iota
 	Bool
 	String
 	Any
 	Custom
+	Slice
+	Rune // Unicode code point
 	Int8 // Numbers ordered by byte count / max size
 	UnsignedInt8
 	Int16
@@ -67,7 +153,120 @@ const (
 	UnsignedInt64
 )
 
-func getCommonTypeId(t1 ActualType, t2 ActualType) TypeId {
+// Names of the types in source, aliases like int are left out
+var typeNames = map[TypeId]string{
+	Void:          "void",
+	Bool:          "bool",
+	String:        "string",
+	Any:           "any",
+	Rune:          "rune",
+	Int8:          "int8",
+	UnsignedInt8:  "uint8",
+	Int16:         "int16",
+	UnsignedInt16: "uint16",
+	Float32:       "float32",
+	Int32:         "int32",
+	UnsignedInt32: "uint32",
+	Float64:       "float64",
+	Complex64:     "complex64",
+	Complex128:    "complex128",
+	Int64:         "int64",
+	UnsignedInt64: "uint64",
+}
+
+// Type suffixes of number literals (10u8, 1.5f64)
+var NumberSuffixes = map[string]TypeId{
+	"i8":  Int8,
+	"u8":  UnsignedInt8,
+	"i16": Int16,
+	"u16": UnsignedInt16,
+	"i32": Int32,
+	"u32": UnsignedInt32,
+	"i64": Int64,
+	"u64": UnsignedInt64,
+	"f32": Float32,
+	"f64": Float64,
+}
+
+// Bits of a number type, for complex numbers the bits of one component
+var numberBits = map[TypeId]int{
+	Int8:          8,
+	UnsignedInt8:  8,
+	Int16:         16,
+	UnsignedInt16: 16,
+	Int32:         32,
+	UnsignedInt32: 32,
+	Int64:         64,
+	UnsignedInt64: 64,
+	Float32:       32,
+	Float64:       64,
+	Complex64:     32,
+	Complex128:    64,
+}
+
+// Bits of the mantissa of floating point number types
+var mantissaBits = map[int]int{
+	32: 24,
+	64: 53,
+}
+
+func isIntegerId(id TypeId) bool {
+	return id >= Int8 && id != Float32 && id != Float64 && id != Complex64 && id != Complex128
+}
+
+func isUnsignedId(id TypeId) bool {
+	return id == UnsignedInt8 || id == UnsignedInt16 || id == UnsignedInt32 || id == UnsignedInt64
+}
+
+// Checks if every value of number type from can be represented by number type to
+func IsWidening(from TypeId, to TypeId) bool {
+	if from == to {
+		return true
+	}
+
+	fromBits, fromNumber := numberBits[from]
+	toBits, toNumber := numberBits[to]
+
+	if !fromNumber || !toNumber {
+		return false
+	}
+
+	fromInteger, toInteger := isIntegerId(from), isIntegerId(to)
+
+	// Integers fit floating point numbers as long as the mantissa holds all of their bits
+	if fromInteger && !toInteger {
+		valueBits := fromBits
+		if !isUnsignedId(from) {
+			valueBits--
+		}
+
+		return valueBits <= mantissaBits[toBits]
+	}
+
+	// Floating point numbers never fit integers
+	if !fromInteger && toInteger {
+		return false
+	}
+
+	// Complex numbers never fit floating point numbers
+	if !toInteger {
+		fromComplex := from == Complex64 || from == Complex128
+		toComplex := to == Complex64 || to == Complex128
+
+		return (toComplex || !fromComplex) && fromBits <= toBits
+	}
+
+	// Unsigned integers need a bigger signed integer, signed integers never fit unsigned ones
+	if isUnsignedId(from) != isUnsignedId(to) {
+		return isUnsignedId(from) && fromBits < toBits
+	}
+
+	return fromBits <= toBits
+}
+
+// Returns the smallest type both number types widen to, Void if there is none.
+// Number type ids are ordered by size, integers only combine to integers.
+func GetCommonTypeId(t1 ActualType, t2 ActualType) TypeId {
 	id1, id2 := t1.Id, t2.Id
 
 	// Types match
@@ -75,15 +274,17 @@ func getCommonTypeId(t1 ActualType, t2 ActualType) TypeId {
 		return id1
 	}
 
-	smallest := min(id1, id2)
-	biggest := max(id1, id2)
+	for id := Int8; id <= UnsignedInt64; id++ {
+		if isIntegerId(id1) && isIntegerId(id2) && !isIntegerId(id) {
+			continue
+		}
 
-	// Custom, Bool cannot be combined
-	if smallest <= Custom {
-		return Void
+		if IsWidening(id1, id) && IsWidening(id2, id) {
+			return id
+		}
 	}
 
-	return biggest
+	return Void
 }
 
 type Scope struct {
@@ -91,6 +292,8 @@ type Scope struct {
 	Vars   []ScopeVar
 	Fns    []ScopeFn
 	Types  []ScopeType
+	Loop   bool     // Scope is the body of a loop
+	Fn     *ScopeFn // Scope is the body of this function
 }
 
 type ScopeVar struct {
@@ -99,7 +302,8 @@ type ScopeVar struct {
 	VarConstant        bool
 	VarValueExpression *Statement
 	VarOfFunction      bool
-	ALLOCATED          bool // true if to deallocate in c compiler!
+	VarTrace           analysis.SourceTrace // Declaration in source
+	ALLOCATED          bool                 // true if to deallocate in c compiler!
 }
 
 type ScopeFn struct {
@@ -107,10 +311,44 @@ type ScopeFn struct {
 	FnArgNames []string
 	FnArgTypes []ActualType
 	FnName     string
+	FnBuiltin  bool // len and append, compiled inline
+	FnNative   bool
+	FnTrace    analysis.SourceTrace // Declaration in source
 }
 
 type ScopeType struct {
-	TypeName string
+	TypeName         string
+	TypeFieldNames   []string
+	TypeFieldTypes   []ActualType
+	TypeVariantNames []string             // Enum
+	TypeVariantTypes [][]ActualType       // ^ payload of each variant
+	TypeTrace        analysis.SourceTrace // Declaration in source
+}
+
+func (t ScopeType) IsEnum() bool {
+	return len(t.TypeVariantNames) > 0
+}
+
+// Returns index of the enum variant, -1 if the type has no such variant
+func (t ScopeType) GetVariant(name string) int {
+	for i, variantName := range t.TypeVariantNames {
+		if variantName == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Returns type of the field, false if the type has no such field
+func (t ScopeType) GetField(name string) (ActualType, bool) {
+	for i, fieldName := range t.TypeFieldNames {
+		if fieldName == name {
+			return t.TypeFieldTypes[i], true
+		}
+	}
+
+	return ActualType{}, false
 }
 
 func (s Scope) GetVariable(name string) *ScopeVar {
@@ -141,6 +379,31 @@ func (s Scope) GetFunction(name string) *ScopeFn {
 	return nil
 }
 
+func (s Scope) IsInLoop() bool {
+	if s.Loop {
+		return true
+	}
+
+	if s.Parent != nil {
+		return s.Parent.IsInLoop()
+	}
+
+	return false
+}
+
+// Returns the function the scope belongs to
+func (s Scope) GetEnclosingFunction() *ScopeFn {
+	if s.Fn != nil {
+		return s.Fn
+	}
+
+	if s.Parent != nil {
+		return s.Parent.GetEnclosingFunction()
+	}
+
+	return nil
+}
+
 func (s Scope) GetType(name string) *ScopeType {
 	for _, t := range s.Types {
 		if t.TypeName == name {
@@ -157,22 +420,31 @@ func (s Scope) GetType(name string) *ScopeType {
 
 type Statement struct {
 	Type        StatementType
-	Children    []*Statement    // Root
-	Left        *Statement      // Binary Expression
-	Right       *Statement      // ^
+	Children    []*Statement    // Root & Enum Declaration: variants as identifier expressions with payload in ArgTypes & Match Statement: arms
+	Left        *Statement      // Binary Expression & Index Expression: array & Slice Expression: sliced value
+	Right       *Statement      // ^ & Index Expression: index & Loop Statement: string iterated by code point (for in)
 	Operator    BinaryOperation // ^
-	Range       string          // Range of NumberExpression (int, float etc)
-	Value       string          // NumberExpression: num value | IdentifierExpression: name | BinaryExpression: operator
-	RunScope    *Statement      // Function Declaration
+	Unary       UnaryOperation  // Unary Expression, operand is Right
+	Range       string          // Type suffix of Number Literal (u8, f64 etc.)
+	Value       string          // NumberExpression: num value | CharacterLiteral: UTF-8 of the code point | IdentifierExpression: name | BinaryExpression: operator | Struct Declaration & Literal: type name | Field Expression: field name, struct is Left | Match Arm: variant, empty for else
+	RunScope    *Statement      // Function Declaration & Conditional Statement & Loop Statement
 	RunCaller   *Statement
-	ArgTypes    []ActualType // ^
-	ArgNames    []string     // ^ & Assignment
-	Types       []ActualType // ^ & Variable Declaration (EMPTY if no vars declared)
-	Expressions []*Statement // Variable Declaration & Assignment
+	ArgTypes    []ActualType // ^ & Conversion Expression: type of the operand & Index/Slice Expression: type of the indexed value & Binary Expression: type of the operands & Function Expression: types of the passed values & Struct Declaration: field types
+	ArgNames    []string     // ^ & Assignment & Struct Declaration & Literal: field names & Match Arm: names bound to the payload
+	Types       []ActualType // ^ & Variable Declaration (EMPTY if no vars declared) & Conversion Expression: type converted to, operand is Right & Index/Slice Expression: resulting type & Variable Assignment: types of the targets
+	Expressions []*Statement // Variable Declaration & Assignment & Return Statement & Struct Literal: field values & Field Expression: payload of enum variant & Array Literal: values & Slice Expression: start and end, nil if omitted
 	Identifiers []*Statement // ^
 	Constant    bool         // Variable Declaration
+	Destructure bool         // ^ of multiple values returned by a single function expression
+	Compound    bool         // Variable Assignment combining the variable with the value using Operator (+= etc.)
 	ArraySizes  []int        // Identifier Expression of array
 	Variadic    bool         // Identifier Expression
+	Condition   *Statement   // Conditional Statement & Loop Statement (nil loops forever) & Match Statement: value matched
+	ElseScope   *Statement   // Conditional Statement: scope or conditional statement of else (if)
+	Initializer *Statement   // Loop Statement: run once before the loop (for) or declaring the variable of each code point (for in)
+	Step        *Statement   // ^ run after each iteration (for)
+	Cleanup     []*Statement // Return & Break & Continue Statement: de-allocations of the variables it leaves
+	Doc         string       // Function & Variable Declaration: text of doc comments before it
 	Trace       analysis.SourceTrace
 
 	// Context
@@ -234,6 +506,10 @@ func PrintAST(statement Statement, i int) {
 	fmt.Println(prefix, "Type:", statement.Type)
 	fmt.Println(prefix, "Value:", statement.Value)
 
+	if statement.Doc != "" {
+		fmt.Println(prefix, "Doc:", strconv.Quote(statement.Doc))
+	}
+
 	if statement.Type == FunctionDeclaration {
 		fmt.Println(prefix, "ArgNames:", statement.ArgNames)
 		fmt.Println(prefix, "ArgTypes:", statement.ArgTypes)
@@ -248,6 +524,10 @@ func PrintAST(statement Statement, i int) {
 		fmt.Println(prefix, "Expressions:", statement.Expressions)
 	}
 
+	if statement.Type == ReturnStatement {
+		fmt.Println(prefix, "Expressions:", statement.Expressions)
+	}
+
 	if statement.Type == MemoryDeAllocation {
 		fmt.Println(prefix, "Var:", statement.ContextVariable)
 	}
@@ -256,6 +536,72 @@ func PrintAST(statement Statement, i int) {
 		fmt.Println(prefix, "Operator:", statement.Operator)
 	}
 
+	if statement.Type == UnaryExpression {
+		fmt.Println(prefix, "Unary:", statement.Unary)
+	}
+
+	if statement.Type == ConversionExpression {
+		fmt.Println(prefix, "Types:", statement.Types)
+	}
+
+	if statement.Type == StructDeclaration {
+		fmt.Println(prefix, "ArgNames:", statement.ArgNames)
+		fmt.Println(prefix, "ArgTypes:", statement.ArgTypes)
+	}
+
+	// Variant of enum declaration
+	if statement.Type == IdentifierExpression && len(statement.ArgTypes) > 0 {
+		fmt.Println(prefix, "ArgTypes:", statement.ArgTypes)
+	}
+
+	if statement.Type == MatchStatement {
+		fmt.Println(prefix, "Condition:")
+		PrintAST(*statement.Condition, i+1)
+	}
+
+	if statement.Type == MatchArm {
+		fmt.Println(prefix, "ArgNames:", statement.ArgNames)
+		fmt.Println(prefix, "RunScope:")
+		PrintAST(*statement.RunScope, i+1)
+	}
+
+	if statement.Type == StructLiteral {
+		fmt.Println(prefix, "ArgNames:", statement.ArgNames)
+		fmt.Println(prefix, "Expressions:", statement.Expressions)
+	}
+
+	if statement.Type == ConditionalStatement {
+		fmt.Println(prefix, "Condition:")
+		PrintAST(*statement.Condition, i+1)
+		fmt.Println(prefix, "RunScope:")
+		PrintAST(*statement.RunScope, i+1)
+
+		if statement.ElseScope != nil {
+			fmt.Println(prefix, "ElseScope:")
+			PrintAST(*statement.ElseScope, i+1)
+		}
+	}
+
+	if statement.Type == LoopStatement {
+		if statement.Initializer != nil {
+			fmt.Println(prefix, "Initializer:")
+			PrintAST(*statement.Initializer, i+1)
+		}
+
+		if statement.Condition != nil {
+			fmt.Println(prefix, "Condition:")
+			PrintAST(*statement.Condition, i+1)
+		}
+
+		if statement.Step != nil {
+			fmt.Println(prefix, "Step:")
+			PrintAST(*statement.Step, i+1)
+		}
+
+		fmt.Println(prefix, "RunScope:")
+		PrintAST(*statement.RunScope, i+1)
+	}
+
 	if statement.Left != nil {
 		fmt.Println(prefix, "Left: ")
 
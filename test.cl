@@ -10,4 +10,5 @@ fn println(string..? f) {
 // Call printf function
 fn main() -> int {
     printf("Hello World! %i\n", 99)
+    return 0
 }
\ No newline at end of file
@@ -1,2 +1,2 @@
-#go run *.go && 
+#go run . emit-c -o test/test.c test.cl && 
 cd test && gcc test.c -o test -Wall && ./test
\ No newline at end of file